@@ -7,6 +7,7 @@ package main
 import (
     "fmt"
     "github.com/docopt/docopt-go"
+    "encoding/json"
     "regexp"
     "strings"
     "reflect"
@@ -14,6 +15,8 @@ import (
     "io"
     "io/ioutil"
     "sort"
+    "strconv"
+    "unicode/utf8"
 )
 
 var Version string = `docopts 0.6.3
@@ -27,10 +30,25 @@ There is NO WARRANTY, to the extent permitted by law.
 var Usage string = `Shell interface for docopt, the CLI description language.
 
 Usage:
-  docopts [options] -h <msg> : [<argv>...]
-  docopts [options] [--no-declare] -A <name>   -h <msg> : [<argv>...]
-  docopts [options] -G <prefix>  -h <msg> : [<argv>...]
-  docopts [options] --no-mangle  -h <msg> : [<argv>...]
+  docopts [options] (-h <msg> | --from-script=<file>) : [<argv>...]
+  docopts [options] [--no-declare] -A <name>   (-h <msg> | --from-script=<file>) : [<argv>...]
+  docopts [options] -G <prefix>  (-h <msg> | --from-script=<file>) : [<argv>...]
+  docopts [options] --no-mangle  (-h <msg> | --from-script=<file>) : [<argv>...]
+  docopts [options] --json  (-h <msg> | --from-script=<file>) : [<argv>...]
+  docopts exec [options] (-h <msg> | --from-script=<file>) : [<argv>...] -- <command>...
+  docopts [options] --from-script=<file> (usage | version)
+  docopts [options] --generate-completion=<shell> (-h <msg> | --from-script=<file>)
+  docopts [options] --generate-man (-h <msg> | --from-script=<file>)
+  docopts [options] --generate-doc=<format> [--output-dir=<dir>] (-h <msg> | (--from-script=<file>)...)
+  docopts complete [options] (-h <msg> | --from-script=<file>) : [<argv>...]
+  docopts lint [options] [-G <prefix>] (-h <msg> | (--from-script=<file>)...)
+  docopts check [options] [-A <name> | -G <prefix>] <script>
+  docopts ast [options] (-h <msg> | --from-script=<file>)
+  docopts schema [options] (-h <msg> | --from-script=<file>)
+  docopts [--env=<name>] get <key> [<index>]
+  docopts [--env=<name>] has <key>
+  docopts [--env=<name>] count <key>
+  docopts [options] [--env=<name>] fail
 
 Options:
   -h <msg>, --help=<msg>        The help message in docopt format.
@@ -45,6 +63,21 @@ Options:
                                 read from standard input, it is read first.
                                 If no argument is given, print docopts's own
                                 version message and quit.
+  --from-script=<file>          Read the help message, and the version message
+                                if -V is not given, from the comments of the
+                                script <file>, see: --marker.
+                                With usage or version, output the message
+                                found and quit.
+  --marker=<marker>             Comment marker of the help message in a script
+                                read with --from-script. With #, the help
+                                message is the comment block starting at the
+                                '# Usage' line up to the first line which is
+                                not a comment, the version message is the block
+                                following a '# ----' line. Any other marker,
+                                like ##?, selects all the lines starting with
+                                it. [default: #]
+  --version-marker=<marker>     Comment marker of the version message in a
+                                script read with another marker, like #?.
   -s <str>, --separator=<str>   The string to use to separate the help message
                                 from the version message when both are given
                                 via standard input. [default: ----]
@@ -62,13 +95,253 @@ Options:
                                 See also: --no-mangle
   --no-mangle                   Output parsed option not suitable for bash eval.
                                 As without -A but full option names are kept.
-                                Rvalue is still shellquoted.
+                                Rvalue is still shellquoted. Refused for
+                                POSIX sh, see: --shell.
+  --sort=<order>                Output order of the parsed arguments: usage for
+                                the order of declaration in the usage, or alpha
+                                for alphabetical order. [default: usage]
+  --mangle-suffix               When mangled names collide, append a suffix
+                                depending on the element kind: _opt for
+                                options, _arg for arguments, _cmd for commands.
+                                Without it, collisions are an error.
+  --reserved=<policy>           What to do when a mangled name would overwrite
+                                a shell special or environment variable like
+                                PATH, HOME, IFS or RANDOM: error, prefix with
+                                '_', or allow. [default: error]
   --no-declare                  Don't output 'declare -A <name>', used only
                                 with -A argument.
+  --quoting=<style>             Quoting of the output values: single for
+                                '...' where newlines are kept as is, ansi-c
+                                for bash $'...' with every non printable byte
+                                escaped, or auto for the minimal safe form per
+                                value: bare word, '...' or $'...'. Only for
+                                bash and zsh. [default: single]
+  --arrays=<layout>             Layout of repeatable arguments with -A: legacy
+                                for the fake nested <name>['FILE,#'] and
+                                <name>['FILE,0'] only, or native to also output
+                                a real indexed array per repeatable argument,
+                                named by --array-name. [default: legacy]
+  --array-name=<pattern>        Name of the native arrays: {assoc} is replaced
+                                by the -A <name> and {name} by the mangled
+                                argument, ARGS_FILE for FILE.
+                                [default: {assoc}_{name}]
+  --json                        Output parsed arguments as a JSON object with
+                                native types instead of Bash code: booleans,
+                                integers for counters, strings, arrays of
+                                strings and null for absent values. Takes
+                                precedence over -A, -G and --no-mangle.
+                                On help or usage error, the message is stored
+                                in the JSON and docopts exits 42 or the code
+                                given by --error-code, see: fail.
+                                Fails if an argument is not valid UTF-8.
+  --error-code=<code>           Exit code of docopts with --json when <argv>
+                                doesn't match the usage. [default: 1]
+  --explain                     Explain on stderr how <argv> matches the
+                                usage, the usage line matched or, on error,
+                                the closest one with the elements missing, and
+                                the unexpected options and extra arguments.
+                                Output is a JSON object with --json.
+  --export                      Export the global variables. Repeatable
+                                arguments, which can't be exported as arrays,
+                                are also exported as with exec: FILE_COUNT,
+                                FILE_0, FILE_1...
+  --env-prefix=<prefix>         With exec, prefix of the environment variables:
+                                <prefix>_{option}.
+  --env-arrays=<encoding>       With exec, encoding of repeatable arguments:
+                                indexed for FILE_COUNT=2 FILE_0=... FILE_1=...
+                                or newline for FILE_COUNT=2 and FILE holding
+                                the values separated by a newline.
+                                [default: indexed]
+  --function                    Output code suitable for parsing the arguments
+                                of a shell function: exit is replaced by
+                                return and variables are declared local.
+  --shell=<name>                Output code for the given shell: bash, zsh,
+                                posix or fish.
+                                With zsh, -A uses typeset and a list assignment
+                                with 1-indexed repeatable arguments: ARGS[FILE,1]
+                                is the first one. Repeatable arguments are
+                                zsh arrays in global mode.
+                                With posix, for dash or busybox sh, only scalars
+                                are output: repeatable arguments are stored as
+                                FILE_COUNT=2 FILE_0=... FILE_1=... and -A is
+                                not supported.
+                                With fish, variables are set with set -g, or
+                                set -l with --function, repeatable arguments
+                                are lists and -A is not supported. Use:
+                                eval (docopts --shell=fish ... | string collect)
+                                [default: bash]
+  --set-positional=<name>       With --shell=posix, the values of the repeatable
+                                argument <name>, like FILE or <file>, reset the
+                                positional parameters with 'set --' instead.
+  --generate-completion=<shell>
+                                Output a completion script for the program
+                                described by the help message, for bash, zsh
+                                or fish: its commands, its options and file
+                                names for arguments named like <file>, FILE
+                                or <path>, directory names for <dir>. With
+                                zsh and fish, options have their description
+                                and alternatives exclude each other. Without
+                                argument, bash is used.
+  --generate-man                Output a man page in roff format generated from
+                                the help message: NAME and DESCRIPTION from
+                                the text before the usage, SYNOPSIS from the
+                                usage patterns, then the other sections like
+                                Options in order. The first line of the
+                                version message is the footer. Install it as
+                                man1/<prog>.1 in a man directory.
+  --generate-doc=<format>      Output the documentation of the program
+                                described by the help message, in markdown or
+                                html: the text before the usage, a synopsis
+                                block, its commands and arguments, and a table
+                                of options with their short and long forms,
+                                argument, default and description, with an
+                                anchor per option.
+  --output-dir=<dir>            With --generate-doc, write a document per
+                                program in <dir>, named after the script, like
+                                naval_fate.sh.md, and an index linking to them,
+                                index.md or index.html. Required when more
+                                than one --from-script is given, where each
+                                script has its own version message.
+  --cword=<n>                   With complete, index in <argv> of the word to
+                                complete, as COMP_CWORD in bash. <argv> starts
+                                with the program name, as COMP_WORDS. Default
+                                is the last word.
   --debug                       Output extra parsing information for debuging.
                                 Output cannot be used in bash eval.
+  --env=<name>                  Name of the environment variable holding the
+                                exported result of docopts --json, used by
+                                get, has, count and fail. Overrides
+                                $DOCOPTS_JSON_VAR.
+                                If none is given, DOCOPTS_JSON is used.
+
+Query actions on a stored --json result:
+  get <key> [<index>]           Output the value of <key>, arrays are output
+                                one element per line or only the element at
+                                <index>.
+  has <key>                     Exit 0 if <key> was given, 1 otherwise.
+  count <key>                   Output the number of occurrences of <key>.
+  fail                          Output bash code displaying the stored help or
+                                error message and exiting with the intended
+                                code: 0 for help, 64 for usage error.
+
+Execute a command:
+  exec                          Parse <argv> with the usage, then replace
+                                docopts by <command>, the parsed arguments are
+                                given as environment variables, named as in
+                                global mode, --no-mangle is refused. <argv>
+                                ends at the first --.
+                                Help is output and docopts exits 0, a usage
+                                error is output on stderr and docopts exits 64.
+
+Complete a command line:
+  complete                      Output the candidates for the word of <argv>
+                                at --cword, one per line: the commands and
+                                options which can come next according to the
+                                usage and start with the word, and the
+                                placeholders of the arguments, like <file>.
+                                Options are offered if the word starts with a
+                                dash or if no argument can come next. See
+                                docopt_complete in docopts.sh.
+
+Check a help message:
+  lint                          Report the mistakes of the help message, or of
+                                each script, one per line as
+                                <file>:<line>:<column>: <severity>: <message>
+                                where <file> is <help> or <stdin> with -h:
+                                options described but used by no pattern,
+                                described twice, or separated from their
+                                description by a single space, [default: ...]
+                                on an option without argument, names which
+                                can't be mangled with the given -G, --shell
+                                and --reserved, and lines that docopt ignores
+                                or misreads because of their indentation.
+                                Exits 0 without issue, 1 if only warnings
+                                were found, 2 for errors.
+
+Check a script:
+  check <script>                Report the references to the parsed arguments
+                                in <script> which don't match its usage, read
+                                as with --from-script, in the same format and
+                                with the same exit codes as lint: unknown keys
+                                of the -A <name> array like ${ARGS[--ouput]},
+                                variables of the -G <prefix> not defined by the
+                                usage, with the closest key as hint, and
+                                options never read. Without -A or -G, they are
+                                detected from the docopts call of the script.
+                                In global mode without prefix, only variables
+                                close to a key and never assigned are reported.
+
+Dump the usage structure:
+  ast                           Output the parsed usage as JSON for external
+                                tools: the format version, the program name,
+                                a pattern tree per usage line, then commands,
+                                arguments and options in order of declaration
+                                with their short and long names, argcount,
+                                argument, default value and repeatability. The
+                                ast_version field changes only on incompatible
+                                changes.
+  schema                        Output the JSON Schema of the object printed by
+                                --json: each key with its type, boolean or
+                                integer for flags and commands, string or null
+                                for values, array for repeatable ones, and its
+                                default. A command given excludes the commands
+                                never used with it, their values are then
+                                constrained by an enum.
 `
 
+// Subcommands come first in docopts's argv and are parsed with their own usage
+// line, see: Subcommand_usage(). The usage of exec and complete ends at ':',
+// the <argv> of the program following it is split before parsing, see:
+// Split_subcommand_argv().
+var Subcommands = map[string]string{
+    "exec": "docopts exec [options] (-h <msg> | --from-script=<file>) :",
+    "complete": "docopts complete [options] (-h <msg> | --from-script=<file>) :",
+    "lint": "docopts lint [options] [-G <prefix>] (-h <msg> | (--from-script=<file>)...)",
+    "check": "docopts check [options] [-A <name> | -G <prefix>] <script>",
+    "ast": "docopts ast [options] (-h <msg> | --from-script=<file>)",
+    "schema": "docopts schema [options] (-h <msg> | --from-script=<file>)",
+}
+
+// The usage of a subcommand: its usage line and the options of docopts.
+func Subcommand_usage(name string) string {
+    return fmt.Sprintf("Usage:\n  %s\n\n%s\n", Subcommands[name],
+        strings.Join(parse_section("options:", Usage), "\n"))
+}
+
+// Split the argv of a subcommand at the first ':' which is not the argument of
+// an option of usage: docopts's own arguments up to ':', and the <argv> of the
+// program, whose options must not be read as docopts's. Without ':', all
+// arguments are docopts's own.
+func Split_subcommand_argv(usage string, argv []string) ([]string, []string) {
+    u, err := Parse_usage(usage)
+    if err != nil {
+        return argv, []string{}
+    }
+    options := New_completion_model(u)
+    for i := 0; i < len(argv); i++ {
+        arg := argv[i]
+        switch {
+        case arg == ":":
+            return argv[:i+1], argv[i+1:]
+        case strings.HasPrefix(arg, "--"):
+            name, eq, _ := partition(arg, "=")
+            if o := options.Option(name); o != nil && o.Argcount > 0 && eq == "" {
+                i++
+            }
+        case strings.HasPrefix(arg, "-"):
+            for j := 1; j < len(arg); j++ {
+                if o := options.Option("-" + arg[j:j+1]); o != nil && o.Argcount > 0 {
+                    if j == len(arg) - 1 {
+                        i++
+                    }
+                    break
+                }
+            }
+        }
+    }
+    return argv, []string{}
+}
+
 // testing trick, out can be mocked to catch stdout and validate
 // https://stackoverflow.com/questions/34462355/how-to-deal-with-the-fmt-golang-library-package-for-cli-testing
 var out io.Writer = os.Stdout
@@ -95,6 +368,26 @@ type Docopts struct {
     Mangle_key bool
     Output_declare bool
     Exit_function bool
+    Json_error_code int
+    Mangle_suffix bool
+    // policy for mangled names overwriting shell variables: error, prefix or allow
+    Reserved string
+    // output for the given shell: bash, zsh, posix or fish, empty is bash
+    Shell string
+    // with posix shell, repeatable argument output with 'set --'
+    Set_positional string
+    // quoting of string values: single, ansi-c or auto, see: Quote()
+    Quoting string
+    // export the global variables, see: --export
+    Export bool
+    // encoding of repeatable arguments with docopts exec: indexed or newline
+    Env_arrays string
+    // layout of repeatable arguments with -A: legacy or native
+    Arrays string
+    // name pattern of the native arrays, see: Native_array_names()
+    Array_name string
+    // output order of the parsed arguments, see: Ordered_keys()
+    Key_order []string
 }
 
 // output bash 4 compatible assoc array, suitable for eval.
@@ -106,26 +399,98 @@ func (d *Docopts) Print_bash_args(bash_assoc string, args docopt.Opts) {
     // 'i' is an integer from 0 to length-1
     // length can be 0, for empty array
 
+    // native array names are checked before any output
+    var native_names map[string]string
+    if d.Arrays == "native" {
+        var err error
+        native_names, err = d.Native_array_names(bash_assoc, args)
+        if err != nil {
+            docopts_error("%v", err)
+        }
+    }
+
     if d.Output_declare {
-        fmt.Fprintf(out, "declare -A %s\n" ,bash_assoc)
+        fmt.Fprintf(out, "%s -A %s\n", d.Get_declare(), bash_assoc)
     }
 
-    for key, value := range args {
+    for _, key := range d.Ordered_keys(args) {
+        value := args[key]
         // some golang tricks here using reflection to loop over the map[]
         rt := reflect.TypeOf(value)
         if IsArray(rt) {
             // all array is outputed even 0 size
             val_arr := value.([]string)
             for index, v := range val_arr {
-                fmt.Fprintf(out, "%s['%s,%d']=%s\n", bash_assoc, Shellquote(key), index, To_bash(v))
+                fmt.Fprintf(out, "%s['%s,%d']=%s\n", bash_assoc, Shellquote(key), index, d.Quote(v))
             }
             // size of the array
             fmt.Fprintf(out, "%s['%s,#']=%d\n", bash_assoc, Shellquote(key), len(val_arr))
         } else {
             // value is not an array
-            fmt.Fprintf(out, "%s['%s']=%s\n", bash_assoc, Shellquote(key), To_bash(value))
+            fmt.Fprintf(out, "%s['%s']=%s\n", bash_assoc, Shellquote(key), d.To_shell(value))
         }
     }
+
+    if native_names != nil {
+        d.Print_bash_native_arrays(native_names, args)
+    }
+}
+
+// With --arrays=native, output a real indexed array per repeatable argument in
+// addition to the fake nested layout of Print_bash_args(), so scripts can use
+// "${ARGS_FILE[@]}". names are given by Native_array_names().
+func (d *Docopts) Print_bash_native_arrays(names map[string]string, args docopt.Opts) {
+    declare := ""
+    if d.Output_declare {
+        declare = d.Get_declare() + " -a "
+    }
+    for _, key := range d.Ordered_keys(args) {
+        if name, ok := names[key]; ok {
+            fmt.Fprintf(out, "%s%s=%s\n", declare, name, d.To_shell(args[key]))
+        }
+    }
+}
+
+// Names of the native arrays for repeatable arguments: key => bash identifier.
+// Docopts.Array_name is a pattern where {assoc} is replaced by the associative
+// array name and {name} by the mangled key. Reserved names follow --reserved.
+func (d *Docopts) Native_array_names(bash_assoc string, args docopt.Opts) (map[string]string, error) {
+    keys := []string{}
+    for _, key := range d.Ordered_keys(args) {
+        if _, ok := args[key].([]string); ok {
+            keys = append(keys, key)
+        }
+    }
+
+    // mangle without prefix, the pattern gives the final name
+    mangler := *d
+    mangler.Global_prefix = ""
+    mangler.Reserved = "allow"
+    names, err := mangler.Mangle_keys(keys)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, key := range keys {
+        name := strings.Replace(d.Array_name, "{assoc}", bash_assoc, -1)
+        name = strings.Replace(name, "{name}", names[key], -1)
+        if ! IsBashIdentifier(name) || name == bash_assoc {
+            return nil, fmt.Errorf("--array-name: cannot name the array of '%s': '%s'", key, name)
+        }
+        if reserved := Reserved_name(name, d.Shell); reserved != "" {
+            if name, err = d.Reserved_policy(key, name, reserved, "--array-name"); err != nil {
+                return nil, err
+            }
+        }
+        names[key] = name
+    }
+
+    collisions := Name_collisions(names)
+    if len(collisions) > 0 {
+        return nil, fmt.Errorf("--array-name: arrays collision: '%s' => '%s'",
+            strings.Join(collisions[0], "', '"), names[collisions[0][0]])
+    }
+    return names, nil
 }
 
 // Check if a value is an array
@@ -167,6 +532,9 @@ func To_bash(v interface{}) string {
     case []string:
         // escape all strings
         arr := v.([]string)
+        if len(arr) == 0 {
+            return "()"
+        }
         arr_out := make([]string, len(arr))
         for i, e := range arr {
             arr_out[i] = Shellquote(e)
@@ -185,29 +553,160 @@ func To_bash(v interface{}) string {
 // suitable for bash eval.
 // If Docopts.Mangle_key: false simply print left-hand side assignment verbatim.
 // used for --no-mangle
+// If Docopts.Exit_function: true, variables are declared local.
+// If Docopts.Export: true, variables are exported, see: Global_declare().
 func (d *Docopts) Print_bash_global(args docopt.Opts) {
-    var new_name string
-    var err error
     var out_buf string
 
-    // value is an interface{}
-    for key, value := range args {
-        if d.Mangle_key {
-            new_name, err = d.Name_mangle(key)
-            if err != nil {
-                docopts_error("%v", err)
-            }
-        } else {
-            new_name = key
+    declare := d.Global_declare()
+
+    names := d.Shell_global_names(args)
+    // arrays can't be exported
+    array_declare := declare
+    if d.Export && d.Mangle_key {
+        array_declare = ""
+        if d.Exit_function {
+            array_declare = "local "
         }
+    }
 
-        out_buf += fmt.Sprintf("%s=%s\n", new_name, To_bash(value))
+    // value is an interface{}
+    for _, key := range d.Ordered_keys(args) {
+        val_arr, is_array := args[key].([]string)
+        if !is_array {
+            out_buf += fmt.Sprintf("%s%s=%s\n", declare, names[key], d.To_shell(args[key]))
+            continue
+        }
+        out_buf += fmt.Sprintf("%s%s=%s\n", array_declare, names[key], d.To_shell(val_arr))
+        if d.Export && d.Mangle_key {
+            out_buf += d.Export_array_source(names, key, val_arr)
+        }
     }
 
     // final output
     fmt.Fprintf(out, "%s", out_buf)
 }
 
+// Variable names for global output: key => name. Names are mangled unless
+// Docopts.Mangle_key is false. Mangling errors stop docopts.
+func (d *Docopts) Global_names(args docopt.Opts) map[string]string {
+    if ! d.Mangle_key {
+        names := make(map[string]string, len(args))
+        for key := range args {
+            names[key] = key
+        }
+        return names
+    }
+
+    // mangle in output order, so the first failing key is always the same
+    names, err := d.Mangle_keys(d.Ordered_keys(args))
+    if err != nil {
+        docopts_error("%v", err)
+    }
+    return names
+}
+
+// Variable names used by Print_bash_global(), Print_zsh_global() and
+// Print_fish_global(): Global_names(), or with Docopts.Export the names of
+// Posix_names(), which also names the count and values of exported arrays.
+// Mangling errors stop docopts.
+func (d *Docopts) Shell_global_names(args docopt.Opts) map[string]string {
+    if ! d.Export || ! d.Mangle_key {
+        return d.Global_names(args)
+    }
+    names, err := d.Posix_names(args)
+    if err != nil {
+        docopts_error("%v", err)
+    }
+    return names
+}
+
+// Performs output as a single JSON object, keys are kept verbatim and values keep
+// their parsed type. This is the foundation of the JSON API, see API_proposal.md.
+// Fails if a key or a value is not valid UTF-8, see: Json_check_utf8().
+func (d *Docopts) Print_json(args docopt.Opts) {
+    if err := d.Json_check_utf8(args); err != nil {
+        docopts_error("%v", err)
+    }
+    members := make([]string, 0, len(args))
+    for _, key := range d.Ordered_keys(args) {
+        members = append(members, To_json(key) + ":" + To_json(args[key]))
+    }
+    fmt.Fprintf(out, "{%s}\n", strings.Join(members, ","))
+}
+
+// JSON strings are UTF-8, encoding/json would silently replace invalid bytes
+// by U+FFFD and the caller would get another argument than the one given.
+// Returns an error naming the first key, in output order, that can't be output.
+func (d *Docopts) Json_check_utf8(args docopt.Opts) error {
+    for _, key := range d.Ordered_keys(args) {
+        if ! utf8.ValidString(key) {
+            return fmt.Errorf("--json: key %q is not valid UTF-8", key)
+        }
+        values := []string{}
+        switch v := args[key].(type) {
+        case string:
+            values = append(values, v)
+        case []string:
+            values = v
+        }
+        for _, v := range values {
+            if ! utf8.ValidString(v) {
+                return fmt.Errorf("--json: value of '%s' is not valid UTF-8: %q", key, v)
+            }
+        }
+    }
+    return nil
+}
+
+// Keys of args in output order: keys found in Docopts.Key_order first, usually
+// the order of declaration in the usage, then remaining keys in alphabetical
+// order. Output is the same from run to run.
+func (d *Docopts) Ordered_keys(args docopt.Opts) []string {
+    keys := make([]string, 0, len(args))
+    seen := make(map[string]bool, len(args))
+    for _, key := range d.Key_order {
+        if _, found := args[key]; found && !seen[key] {
+            keys = append(keys, key)
+            seen[key] = true
+        }
+    }
+
+    remaining := []string{}
+    for key := range args {
+        if !seen[key] {
+            remaining = append(remaining, key)
+        }
+    }
+    sort.Strings(remaining)
+    return append(keys, remaining...)
+}
+
+// Convert parsed arguments to a JSON text. HTML escaping is disabled so
+// keys like <file> are output as is.
+func To_json(v interface{}) string {
+    var buf strings.Builder
+    enc := json.NewEncoder(&buf)
+    enc.SetEscapeHTML(false)
+    if err := enc.Encode(v); err != nil {
+        panic(fmt.Sprintf("To_json():%v", err))
+    }
+    return strings.TrimRight(buf.String(), "\n")
+}
+
+// Same as To_json() indented by two spaces, for documents read by humans too,
+// ends with a new line.
+func To_json_indent(v interface{}) (string, error) {
+    var buf strings.Builder
+    enc := json.NewEncoder(&buf)
+    enc.SetEscapeHTML(false)
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(v); err != nil {
+        return "", err
+    }
+    return buf.String(), nil
+}
+
 // Transform a parsed option or place-holder name into a bash identifier if possible.
 // It Docopts.Global_prefix is prepended if given, wrong prefix may produce invalid
 // bash identifier and this method will fail.
@@ -240,16 +739,194 @@ func (d *Docopts) Name_mangle(elem string) (string, error) {
         return "", fmt.Errorf("cannot transform into a bash identifier: '%s' => '%s'", elem, v)
     }
 
+    if reserved := Reserved_name(v, d.Shell); reserved != "" {
+        return d.Reserved_policy(elem, v, reserved, "-G <prefix>")
+    }
+
     return v, nil
 }
 
+// Apply --reserved to the name mangled from elem which would overwrite the
+// shell variable reserved: the name is prefixed, kept, or an error mentions
+// hint, the way to rename it.
+func (d *Docopts) Reserved_policy(elem, name, reserved, hint string) (string, error) {
+    message := fmt.Sprintf("'%s' => '%s' would overwrite the shell variable %s, " +
+        "use %s or --reserved=prefix", elem, name, reserved, hint)
+    switch d.Reserved {
+    case "prefix":
+        return Reserved_prefix + name, nil
+    case "allow":
+        return name, nil
+    }
+    return "", fmt.Errorf("%s", message)
+}
+
+// Shell special and environment variables, a mangled name must not overwrite them.
+var Reserved_names = []string{
+    "BASH", "BASHOPTS", "BASHPID", "BASH_ALIASES", "BASH_ARGC", "BASH_ARGV",
+    "BASH_ARGV0", "BASH_CMDS", "BASH_COMMAND", "BASH_COMPAT", "BASH_ENV",
+    "BASH_EXECUTION_STRING", "BASH_LINENO", "BASH_LOADABLES_PATH", "BASH_REMATCH",
+    "BASH_SOURCE", "BASH_SUBSHELL", "BASH_VERSINFO", "BASH_VERSION",
+    "BASH_XTRACEFD", "CDPATH", "CHILD_MAX", "COLUMNS", "COMP_CWORD", "COMP_KEY",
+    "COMP_LINE", "COMP_POINT", "COMP_TYPE", "COMP_WORDBREAKS", "COMP_WORDS",
+    "COMPREPLY", "COPROC", "DIRSTACK", "EMACS", "ENV", "EPOCHREALTIME",
+    "EPOCHSECONDS", "EUID", "EXECIGNORE", "FCEDIT", "FIGNORE", "FUNCNAME",
+    "FUNCNEST", "GLOBIGNORE", "GROUPS", "HISTCMD", "HISTCONTROL", "HISTFILE",
+    "HISTFILESIZE", "HISTIGNORE", "HISTSIZE", "HISTTIMEFORMAT", "HOME",
+    "HOSTFILE", "HOSTNAME", "HOSTTYPE", "IFS", "IGNOREEOF", "INPUTRC",
+    "INSIDE_EMACS", "LANG", "LC_ALL", "LC_COLLATE", "LC_CTYPE", "LC_MESSAGES",
+    "LC_NUMERIC", "LC_TIME", "LD_LIBRARY_PATH", "LD_PRELOAD", "LINENO", "LINES",
+    "LOGNAME", "MACHTYPE", "MAIL", "MAILCHECK", "MAILPATH", "MAPFILE", "OLDPWD",
+    "OPTARG", "OPTERR", "OPTIND", "OSTYPE", "PATH", "PIPESTATUS",
+    "POSIXLY_CORRECT", "PPID", "PROMPT_COMMAND", "PROMPT_DIRTRIM", "PS0", "PS1",
+    "PS2", "PS3", "PS4", "PWD", "RANDOM", "READLINE_ARGUMENT", "READLINE_LINE",
+    "READLINE_MARK", "READLINE_POINT", "REPLY", "SECONDS", "SHELL", "SHELLOPTS",
+    "SHLVL", "SRANDOM", "TERM", "TIMEFORMAT", "TMOUT", "TMPDIR", "UID", "USER",
+    "histchars",
+}
+
+// Reserved names also matched case-insensitively, a lower case <path> or --home
+// is too close to the real variable to be safe.
+var Reserved_names_nocase = []string{
+    "CDPATH", "HOME", "IFS", "OLDPWD", "PATH", "PWD", "RANDOM",
+}
+
+// zsh special parameters, in addition to Reserved_names
+var Reserved_names_zsh = []string{
+    "ARGC", "ERRNO", "KEYBOARD_HACK", "MATCH", "MBEGIN", "MEND",
+    "PROMPT", "PROMPT2", "PROMPT3", "PROMPT4", "RPROMPT", "RPROMPT2", "RPS1",
+    "RPS2", "TRY_BLOCK_ERROR", "ZDOTDIR", "ZSH_ARGZERO", "ZSH_EVAL_CONTEXT",
+    "ZSH_NAME", "ZSH_PATCHLEVEL", "ZSH_SUBSHELL", "ZSH_VERSION", "aliases",
+    "argv", "builtins", "cdpath", "commands", "dirstack", "fignore", "fpath",
+    "funcfiletrace", "funcsourcetrace", "funcstack", "functions", "functrace",
+    "history", "historywords", "jobdirs", "jobstates", "jobtexts", "mailpath",
+    "manpath", "match", "mbegin", "mend", "module_path", "modules", "options",
+    "parameters", "path", "pipestatus", "prompt", "psvar", "reply", "signals",
+    "status", "termcap", "terminfo", "userdirs", "widgets",
+}
+
+// fish special and read-only variables, in addition to Reserved_names
+var Reserved_names_fish = []string{
+    "CMD_DURATION", "FISH_VERSION", "_", "argv", "fish_bind_mode",
+    "fish_kill_signal", "fish_killring", "fish_pid", "fish_private_mode",
+    "history", "hostname", "last_pid", "pipestatus", "status",
+    "status_generation", "umask", "version",
+}
+
+// per shell reserved names, see: --shell
+var Reserved_names_shell = map[string][]string{
+    "zsh": Reserved_names_zsh,
+    "fish": Reserved_names_fish,
+}
+
+// prepended to reserved names with --reserved=prefix
+const Reserved_prefix = "_"
+
+// Returns the shell variable overwritten by the identifier name in the given
+// shell (see: --shell), or an empty string if name is safe.
+func Reserved_name(name string, shell string) string {
+    for _, r := range Reserved_names {
+        if name == r {
+            return r
+        }
+    }
+    for _, r := range Reserved_names_shell[shell] {
+        if name == r {
+            return r
+        }
+    }
+    for _, r := range Reserved_names_nocase {
+        if strings.EqualFold(name, r) {
+            return r
+        }
+    }
+    return ""
+}
+
+// Mangle all keys with Name_mangle, returns a map key => bash identifier.
+// Keys mangled to the same identifier would silently overwrite each other, all
+// colliding groups are reported as an error, unless Docopts.Mangle_suffix is true:
+// colliding names are then suffixed by Mangle_suffix(). Names still colliding
+// after suffixing are an error too.
+func (d *Docopts) Mangle_keys(keys []string) (map[string]string, error) {
+    names := make(map[string]string, len(keys))
+    for _, key := range keys {
+        name, err := d.Name_mangle(key)
+        if err != nil {
+            return nil, err
+        }
+        names[key] = name
+    }
+
+    if d.Mangle_suffix {
+        for _, group := range Name_collisions(names) {
+            for _, key := range group {
+                names[key] += Mangle_suffix(key)
+            }
+        }
+    }
+
+    collisions := Name_collisions(names)
+    if len(collisions) > 0 {
+        msg := make([]string, len(collisions))
+        for i, group := range collisions {
+            msg[i] = fmt.Sprintf("'%s' => '%s'", strings.Join(group, "', '"), names[group[0]])
+        }
+        return nil, fmt.Errorf("mangled names collision: %s", strings.Join(msg, "; "))
+    }
+
+    return names, nil
+}
+
+// Group keys having the same mangled name, returns only groups of 2 keys or
+// more. Groups and keys are sorted.
+func Name_collisions(names map[string]string) [][]string {
+    by_name := make(map[string][]string)
+    for key, name := range names {
+        by_name[name] = append(by_name[name], key)
+    }
+
+    collisions := [][]string{}
+    for _, group := range by_name {
+        if len(group) > 1 {
+            sort.Strings(group)
+            collisions = append(collisions, group)
+        }
+    }
+    sort.Slice(collisions, func(i, j int) bool {
+        return names[collisions[i][0]] < names[collisions[j][0]]
+    })
+    return collisions
+}
+
+// Suffix used by --mangle-suffix depending on the kind of usage element:
+// _opt for options, _arg for arguments and _cmd for commands.
+func Mangle_suffix(elem string) string {
+    if Match(`^-[^-]$`, elem) || Match(`^--.+$`, elem) {
+        return "_opt"
+    }
+    if Match(`^<.*>$`, elem) || IsUpper(elem) {
+        return "_arg"
+    }
+    return "_cmd"
+}
+
+// Same rule as docopt: an element all in uppercase, with at least one upper
+// case letter is an argument.
+func IsUpper(s string) bool {
+    return strings.ToUpper(s) == s && strings.ToLower(s) != s
+}
+
 // helper for lazy typing
 func Match(regex string, source string) bool {
     matched, _ := regexp.MatchString(regex, source)
     return matched
 }
 
-// Experimental: Change bash exit source code based on '--function' parameter
+// Exit code intended for the caller on usage error: EX_USAGE in sysexits(3)
+const Exit_usage = 64
+
+// Change bash exit source code based on '--function' parameter
 func (d *Docopts) Get_exit_code(exit_code int) (str_code string) {
     if d.Exit_function {
         str_code = fmt.Sprintf("return %d", exit_code)
@@ -259,19 +936,46 @@ func (d *Docopts) Get_exit_code(exit_code int) (str_code string) {
     return
 }
 
+// Change bash variable declaration based on '--function' parameter, variables
+// must stay local to the function.
+func (d *Docopts) Get_declare() string {
+    if d.Exit_function {
+        return "local"
+    }
+    return "declare"
+}
+
+// Bash source code displaying message, on stderr if to_stderr is true, then
+// stopping the caller with exit_code.
+func (d *Docopts) Bash_fail_source(message string, to_stderr bool, exit_code int) string {
+    redirect := ""
+    if to_stderr {
+        redirect = " >&2"
+    }
+    if d.Shell == "fish" {
+        return fmt.Sprintf("printf '%%s\\n' '%s'%s\n%s\n", Fishquote(message), redirect, d.Get_exit_code(exit_code))
+    }
+    if d.Shell == "posix" || d.Shell == "zsh" {
+        // echo of dash and zsh interprets backslash sequences
+        return fmt.Sprintf("printf '%%s\\n' '%s'%s\n%s\n", Shellquote(message), redirect, d.Get_exit_code(exit_code))
+    }
+    return fmt.Sprintf("echo '%s'%s\n%s\n", Shellquote(message), redirect, d.Get_exit_code(exit_code))
+}
+
+// Format the message displayed on usage error.
+func Error_message(err error, usage string) string {
+    return fmt.Sprintf("error: %s\n%s", err.Error(), usage)
+}
+
 // Our HelpHandler which outputs bash source code to be evaled as error and stop or
 // display program's help or version.
 func (d *Docopts) HelpHandler_for_bash_eval (err error, usage string) {
     if err != nil {
-        fmt.Printf("echo 'error: %s\n%s' >&2\n%s\n",
-            Shellquote(err.Error()),
-            Shellquote(usage),
-            d.Get_exit_code(64),
-        )
+        fmt.Print(d.Bash_fail_source(Error_message(err, usage), true, Exit_usage))
         os.Exit(1)
     } else {
         // --help or --version found and --no-help was not given
-        fmt.Printf("echo '%s'\n%s\n", Shellquote(usage), d.Get_exit_code(0))
+        fmt.Print(d.Bash_fail_source(usage, false, 0))
         os.Exit(0)
     }
 }
@@ -326,14 +1030,86 @@ func docopts_error(msg string, err error) {
     os.Exit(1)
 }
 
+// Perform get, has, count or fail on the JSON stored in the environment variable env_name.
+func (d *Docopts) json_action(arguments docopt.Opts, env_name string) {
+    stored, err := Load_json_env(env_name)
+    if err != nil {
+        docopts_error("%v", err)
+    }
+
+    failure, err := Json_get_failure(stored)
+    if err != nil {
+        docopts_error("%v", err)
+    }
+    if arguments["fail"].(bool) {
+        if failure != nil {
+            fmt.Fprint(out, d.Bash_fail_source(failure.Message, failure.Status == "error", failure.Exit_code))
+        }
+        return
+    }
+    if failure != nil {
+        docopts_error(fmt.Sprintf("no parsed arguments in %s, see: docopts fail", env_name), nil)
+    }
+
+    key := arguments["<key>"].(string)
+    if arguments["get"].(bool) {
+        index, _ := arguments.String("<index>")
+        err = Json_get(stored, key, index)
+    } else if arguments["has"].(bool) {
+        var found bool
+        found, err = Json_has(stored, key)
+        if err == nil && !found {
+            os.Exit(1)
+        }
+    } else {
+        var n int
+        n, err = Json_count(stored, key)
+        if err == nil {
+            fmt.Fprintf(out, "%d\n", n)
+        }
+    }
+
+    if err != nil {
+        docopts_error("%v", err)
+    }
+}
+
 func main() {
-    golang_parser := &docopt.Parser{
-      OptionsFirst: true,
-      SkipHelpFlags: true,
-      HelpHandler: HelpHandler_golang,
+    docopts_argv := os.Args[1:]
+    var subcommand string
+    if len(docopts_argv) > 0 && Subcommands[docopts_argv[0]] != "" {
+        subcommand = docopts_argv[0]
     }
+    exec_mode := subcommand == "exec"
+    complete_mode := subcommand == "complete"
+    lint_mode := subcommand == "lint"
+    check_mode := subcommand == "check"
+    ast_mode := subcommand == "ast"
+    schema_mode := subcommand == "schema"
 
-    arguments, err := golang_parser.ParseArgs(Usage, nil, Version)
+    var arguments docopt.Opts
+    var argv []string
+    var err error
+    if subcommand != "" {
+        usage := Subcommand_usage(subcommand)
+        docopts_argv, argv = Split_subcommand_argv(usage, docopts_argv)
+        subcommand_parser := &docopt.Parser{
+          SkipHelpFlags: true,
+          HelpHandler: HelpHandler_golang,
+        }
+        arguments, err = subcommand_parser.ParseArgs(usage, docopts_argv, Version)
+    } else {
+        // with OptionsFirst, the <argv> following ':' is not read as our options
+        golang_parser := &docopt.Parser{
+          OptionsFirst: true,
+          SkipHelpFlags: true,
+          HelpHandler: HelpHandler_golang,
+        }
+        arguments, err = golang_parser.ParseArgs(Usage, Generate_completion_argv(docopts_argv), Version)
+        if err == nil {
+            argv = arguments["<argv>"].([]string)
+        }
+    }
 
     if err != nil {
         msg := fmt.Sprintf("mypanic: %v\n", err)
@@ -350,23 +1126,129 @@ func main() {
         Global_prefix: "",
         Mangle_key: true,
         Output_declare: true,
-        // Exit_function is experimental
         Exit_function: false,
+        Json_error_code: 1,
     }
 
-    // parse docopts's own arguments
-    argv := arguments["<argv>"].([]string)
-    doc := arguments["--help"].(string)
+    d.Exit_function = arguments["--function"].(bool)
+    d.Shell = arguments["--shell"].(string)
+    if ! Match(`^(bash|zsh|posix|fish)$`, d.Shell) {
+        docopts_error(fmt.Sprintf("--shell: unsupported shell: '%s'", d.Shell), nil)
+    }
+
+    // actions on a previous --json result
+    if subcommand == "" && (arguments["get"].(bool) || arguments["has"].(bool) ||
+        arguments["count"].(bool) || arguments["fail"].(bool)) {
+        env_opt, _ := arguments.String("--env")
+        d.json_action(arguments, Json_env_name(env_opt))
+        return
+    }
+
+    // parse docopts's own arguments, usage and version are not subcommands
+    doc, _ := arguments.String("--help")
+    print_usage, _ := arguments.Bool("usage")
+    print_version, _ := arguments.Bool("version")
     bash_version, _ := arguments.String("--version")
+    // repeatable only in some usage lines
+    var scripts []string
+    switch script := arguments["--from-script"].(type) {
+    case []string:
+        scripts = script
+    case string:
+        scripts = []string{script}
+    }
+    version_marker, _ := arguments.String("--version-marker")
+    marker := arguments["--marker"].(string)
+    if len(scripts) == 1 && !lint_mode {
+        script := scripts[0]
+        script_usage, script_version, err := Read_script(script, marker, version_marker)
+        if err != nil {
+            docopts_error("%v", err)
+        }
+        if script_usage == "" && !print_version {
+            docopts_error("%v", Script_usage_error(script, marker))
+        }
+        if print_usage {
+            fmt.Println(script_usage)
+            return
+        }
+        if print_version {
+            if script_version != "" {
+                fmt.Println(script_version)
+            }
+            return
+        }
+        doc = script_usage
+        if bash_version == "" {
+            bash_version = script_version
+        }
+    }
     options_first := arguments["--options-first"].(bool)
     no_help :=  arguments["--no-help"].(bool)
     separator := arguments["--separator"].(string)
     d.Mangle_key = ! arguments["--no-mangle"].(bool)
     d.Output_declare = ! arguments["--no-declare"].(bool)
+    d.Mangle_suffix = arguments["--mangle-suffix"].(bool)
+    d.Reserved = arguments["--reserved"].(string)
+    d.Set_positional, _ = arguments.String("--set-positional")
+    if d.Set_positional != "" && d.Shell != "posix" {
+        docopts_error("--set-positional: only supported with --shell=posix", nil)
+    }
+    d.Quoting = arguments["--quoting"].(string)
+    if ! Match(`^(single|ansi-c|auto)$`, d.Quoting) {
+        docopts_error(fmt.Sprintf("--quoting: unknown style: '%s'", d.Quoting), nil)
+    }
+    if d.Quoting != "single" && (d.Shell == "posix" || d.Shell == "fish") {
+        docopts_error(fmt.Sprintf("--quoting=%s: $'...' strings are not supported by %s",
+            d.Quoting, Shell_name(d.Shell)), nil)
+    }
+    d.Arrays = arguments["--arrays"].(string)
+    if ! Match(`^(legacy|native)$`, d.Arrays) {
+        docopts_error(fmt.Sprintf("--arrays: unknown layout: '%s'", d.Arrays), nil)
+    }
+    d.Array_name = arguments["--array-name"].(string)
+    if ! Match(`^(error|prefix|allow)$`, d.Reserved) {
+        docopts_error(fmt.Sprintf("--reserved: unknown policy: '%s'", d.Reserved), nil)
+    }
+    json_output := arguments["--json"].(bool)
+    d.Export = arguments["--export"].(bool)
+    d.Env_arrays = arguments["--env-arrays"].(string)
+    if ! Match(`^(indexed|newline)$`, d.Env_arrays) {
+        docopts_error(fmt.Sprintf("--env-arrays: unknown encoding: '%s'", d.Env_arrays), nil)
+    }
+    if _, err := arguments.String("-A"); err == nil && d.Export && !json_output {
+        docopts_error("--export: associative arrays can't be exported, use global variables", nil)
+    }
+    if _, err := arguments.String("-A"); err == nil && !json_output {
+        if d.Shell == "posix" || d.Shell == "fish" {
+            docopts_error(fmt.Sprintf("-A: %s has no associative array, use global variables" +
+                " with -G <prefix> instead, or --json", Shell_name(d.Shell)), nil)
+        }
+    }
+    if ! d.Mangle_key && d.Shell == "posix" && !json_output {
+        docopts_error("--no-mangle: POSIX sh has no associative array, keys can't be" +
+            " output as variables, use -G <prefix> instead, or --json", nil)
+    }
+    if ! d.Mangle_key && exec_mode {
+        docopts_error("--no-mangle: exec passes environment variables, keys can't be" +
+            " used as names, use --env-prefix=<prefix> instead", nil)
+    }
+    d.Json_error_code, err = strconv.Atoi(arguments["--error-code"].(string))
+    if err != nil {
+        docopts_error("--error-code: not an integer: %v", err)
+    }
     global_prefix, err := arguments.String("-G")
     if err == nil {
         d.Global_prefix = global_prefix
     }
+    var command []string
+    if exec_mode {
+        d.Global_prefix, _ = arguments.String("--env-prefix")
+        argv, command, err = Split_exec_argv(argv)
+        if err != nil {
+            docopts_error("%v", err)
+        }
+    }
 
     // read from stdin
     if doc == "-" && bash_version == "-" {
@@ -390,8 +1272,165 @@ func main() {
         bash_version = string(bytes)
     }
 
+    if check_mode {
+        var mode Check_mode
+        mode.Assoc, _ = arguments.String("-A")
+        mode.Prefix = d.Global_prefix
+        script := arguments["<script>"].(string)
+        issues := Check_script_file(script, marker, mode, d)
+        for _, issue := range issues {
+            fmt.Fprintln(out, issue.Format(script))
+        }
+        os.Exit(Lint_status(issues))
+    }
+
+    // positions are reported in the help message as given
+    if lint_mode {
+        all := []Lint_issue{}
+        report := func(name string, issues []Lint_issue) {
+            for _, issue := range issues {
+                fmt.Fprintln(out, issue.Format(name))
+            }
+            all = append(all, issues...)
+        }
+        for _, script := range scripts {
+            report(script, Lint_script(script, marker, d))
+        }
+        if len(scripts) == 0 {
+            name := "<help>"
+            if arguments["--help"] == "-" {
+                name = "<stdin>"
+            }
+            report(name, Lint_usage(doc, d))
+        }
+        os.Exit(Lint_status(all))
+    }
+
     doc = strings.TrimSpace(doc)
     bash_version = strings.TrimSpace(bash_version)
+
+    if shell, err := arguments.String("--generate-completion"); err == nil {
+        if ! Match(`^(bash|zsh|fish)$`, shell) {
+            docopts_error(fmt.Sprintf("--generate-completion: unsupported shell: '%s'", shell), nil)
+        }
+        usage, err := Parse_usage(doc)
+        if err != nil {
+            docopts_error("--generate-completion: %v", err)
+        }
+        switch shell {
+        case "zsh":
+            Print_zsh_completion(New_completion_model(usage))
+        case "fish":
+            Print_fish_completion(New_completion_model(usage))
+        default:
+            Print_bash_completion(New_completion_model(usage))
+        }
+        return
+    }
+
+    if arguments["--generate-man"].(bool) {
+        if len(scripts) == 1 {
+            // NAME and DESCRIPTION come from the comments preceding the usage,
+            // as with --generate-doc
+            description, err := Read_script_description(scripts[0], marker)
+            if err != nil {
+                docopts_error("%v", err)
+            }
+            if description != "" {
+                doc = description + "\n\n" + doc
+            }
+        }
+        if err := Print_man(doc, bash_version); err != nil {
+            docopts_error("--generate-man: %v", err)
+        }
+        return
+    }
+
+    if format, err := arguments.String("--generate-doc"); err == nil {
+        if ! Match(`^(markdown|html)$`, format) {
+            docopts_error(fmt.Sprintf("--generate-doc: unsupported format: '%s'", format), nil)
+        }
+        output_dir, err := arguments.String("--output-dir")
+        if err != nil && len(scripts) > 1 {
+            docopts_error("--generate-doc: --output-dir is required with more than one script", nil)
+        }
+        pages := []*Doc_page{}
+        for _, script := range scripts {
+            page, err := Read_doc_script(script, marker, version_marker)
+            if err != nil {
+                docopts_error("%v", err)
+            }
+            pages = append(pages, page)
+        }
+        if len(scripts) == 1 {
+            // -V takes precedence over the version of the script
+            pages[0].Version, _, _ = partition(bash_version, "\n")
+        } else if len(scripts) == 0 {
+            page, err := New_doc_page(doc, bash_version)
+            if err != nil {
+                docopts_error("--generate-doc: %v", err)
+            }
+            pages = append(pages, page)
+        }
+        if output_dir == "" {
+            fmt.Fprint(out, Doc_render(format, pages[0]))
+            return
+        }
+        files, err := Write_doc(format, pages, output_dir)
+        if err != nil {
+            docopts_error("--generate-doc: %v", err)
+        }
+        for _, file := range files {
+            fmt.Fprintln(out, file)
+        }
+        return
+    }
+
+    if ast_mode {
+        ast, err := Ast_json(doc)
+        if err != nil {
+            docopts_error("ast: %v", err)
+        }
+        fmt.Fprint(out, ast)
+        return
+    }
+
+    if schema_mode {
+        schema, err := Schema_json(doc)
+        if err != nil {
+            docopts_error("schema: %v", err)
+        }
+        fmt.Fprint(out, schema)
+        return
+    }
+
+    if complete_mode {
+        usage, err := Parse_usage(doc)
+        if err != nil {
+            docopts_error("complete: %v", err)
+        }
+        cword := len(argv) - 1
+        if n, err := arguments.String("--cword"); err == nil {
+            if cword, err = strconv.Atoi(n); err != nil {
+                docopts_error("--cword: not an integer: %v", err)
+            }
+        }
+        for _, candidate := range New_completion_model(usage).Complete(argv, cword, options_first) {
+            fmt.Fprintln(out, candidate)
+        }
+        return
+    }
+
+    switch arguments["--sort"].(string) {
+    case "usage":
+        // on failure docopt reports the error, alphabetical order is kept
+        if usage, err := Parse_usage(doc); err == nil {
+            d.Key_order = usage.Keys()
+        }
+    case "alpha":
+    default:
+        docopts_error(fmt.Sprintf("--sort: unknown order: '%s'", arguments["--sort"]), nil)
+    }
     if debug {
         fmt.Printf("%20s : %v\n", "doc", doc)
         fmt.Printf("%20s : %v\n", "bash_version", bash_version)
@@ -403,19 +1442,56 @@ func main() {
       OptionsFirst: options_first,
       SkipHelpFlags: no_help,
     }
+    if json_output {
+        parser.HelpHandler = d.HelpHandler_for_json
+    }
+    if exec_mode {
+        parser.HelpHandler = HelpHandler_for_exec
+    }
+    var explanation *Explanation
+    if arguments["--explain"].(bool) {
+        // on usage parse error docopt reports it
+        if usage, err := Parse_usage(doc); err == nil {
+            explanation = Explain(usage, argv, options_first)
+            handler := parser.HelpHandler
+            parser.HelpHandler = func(err error, usage string) {
+                if err != nil {
+                    Print_explanation(explanation, json_output)
+                }
+                handler(err, usage)
+            }
+        }
+    }
     bash_args, err := parser.ParseArgs(doc, argv, bash_version)
     if err == nil {
         if debug {
             print_args(bash_args, "bash")
             fmt.Println("----------------------------------------")
         }
+        if explanation != nil {
+            Print_explanation(explanation, json_output)
+        }
         name, err := arguments.String("-A")
-        if err == nil {
+        if exec_mode {
+            docopts_error("%v", d.Exec(bash_args, command))
+        } else if json_output {
+            d.Print_json(bash_args)
+        } else if err == nil {
             if ! IsBashIdentifier(name) {
                 fmt.Printf("-A: not a valid Bash identifier: '%s'", name)
                 return
             }
-            d.Print_bash_args(name, bash_args)
+            if d.Shell == "zsh" {
+                d.Print_zsh_args(name, bash_args)
+            } else {
+                d.Print_bash_args(name, bash_args)
+            }
+        } else if d.Shell == "zsh" {
+            d.Print_zsh_global(bash_args)
+        } else if d.Shell == "posix" {
+            d.Print_posix_global(bash_args)
+        } else if d.Shell == "fish" {
+            d.Print_fish_global(bash_args)
         } else {
             d.Print_bash_global(bash_args)
         }
@@ -16,29 +16,18 @@ docopt_sh_dir="$(dirname "$docopt_sh_me")"
 
 # fetch Usage: from the given filename
 # usually $0 in the main level script
+# The comment block starting at "# Usage:" up to the first line which is not
+# a comment, one level of comment markup is removed.
+# See: docopts --from-script, --marker for ##? style markers.
 docopt_get_help_string() {
-    local myfname=$1
-    # filter the block (/!\ all blocks) starting at a "# Usage:" and ending
-    # at an empty line, one level of comment markup is removed
-    #
-    ## sed -n -e '/^# Usage:/,/\(^# \?----\|^$\)/ { /----/ d; s/^# \?//p }' rock_no-stdin_example.sh
-
-    # -n : no print output
-    # -e : pass sed code inline
-    # /^# Usage:/,/^$/ : filter range blocks from '# Usage:' to empty line
-    #  s/^# \?// : substitute comment marker and an optional space
-    #  p : print
-    sed -n -e '/^# Usage:/,/^$/ s/^# \?//p' < $myfname
+    docopts --from-script="$1" usage
 }
 
 # fetch version information from the given filename or string
-# usually $0 in the main level script, or the help string extracted
-# by docopt_get_help_string()
+# usually $0 in the main level script, the comment block following "# ----"
 docopt_get_version_string() {
     if [[ -f "$1" ]] ; then
-        # filter the block (all blocks) starting at a "# Usage:" and ending
-        # at an empty line, one level of comment markup is removed
-        sed -n -e '/^# ----/,/^$/ s/^# \?//p' < "$1"
+        docopts --from-script="$1" version
     else
         # use docopts --separator behavior
         echo "$1"
@@ -51,6 +40,8 @@ docopt_get_version_string() {
 #   ARGS['FILE,1']=somefile2
 #   ARGS['FILE,2']=somefile3
 # Usage: myarray=( $(docopt_get_values ARGS FILE") )
+# Values containing spaces are split, prefer: docopts -A ARGS --arrays=native
+# which outputs "${ARGS_FILE[@]}" directly.
 docopt_get_values() {
     local opt=$2
     local ref="\${$1[$opt,#]}"
@@ -80,13 +71,33 @@ docopt_get_eval_array() {
     done
 }
 
+# JSON API: convert a repeatable option stored by docopts --json into a bash
+# ARRAY, without eval, works with bash 3.2 too.
+# Usage:
+#   export DOCOPTS_JSON=$(docopts --json -h "$help" : "$@")
+#   docopt_get_json_array FILE myarray
+docopt_get_json_array() {
+    local key=$1
+    local nb_val value
+    nb_val=$(docopts count "$key") || return 1
+    local i=0
+    read -r -a "$2" <<< ""
+    while [[ $i -lt $nb_val ]] ; do
+        # read -d '' keeps the new lines of the value, only the one ending the
+        # output of docopts get is removed
+        IFS= read -r -d '' value < <(docopts get "$key" $i; printf '\0')
+        printf -v "$2[$i]" '%s' "${value%$'\n'}"
+        i=$(($i + 1))
+    done
+}
+
 # Auto parser for the same docopts usage over scripts, for lazyness.
 #
 # It uses this convention:
 #  - help string in: $HELP (modified at gobal scope)
-#  - Usage is extracted by docopt_get_help_string at beginning of the script
+#  - Usage is extracted by docopts --from-script at beginning of the script
 #  - arguments are evaluated at global scope in the bash 4 assoc $ARGS
-#  - no version information is handled
+#  - version information is the "# ----" comment block, if any
 #
 docopt_auto_parse() {
     local script_fname=$1
@@ -95,12 +106,11 @@ docopt_auto_parse() {
     HELP="$(docopt_get_help_string "$script_fname")"
     # $ARGS[] assoc array must be declared outside of this function
     # or it's scope will be local, that's why we don't print it.
-    docopts -A ARGS --no-declare -h "$HELP" : "$@"
-    res=$?
-    return $res
+    docopts -A ARGS --no-declare --from-script="$script_fname" : "$@"
 }
 
 # Extract the raw value of a parsed docopts output.
+# Note: with docopts --json, values keep their type, see: docopts get KEY
 # arguments:
 #  - assoc: the docopts assoc name
 #  - key:   the wanted key
@@ -135,6 +145,27 @@ docopt_print_ARGS() {
     done
 }
 
+# Generic bash completion for scripts holding their usage in a "# Usage:"
+# comment block, see docopt_get_help_string. Candidates are computed by
+# docopts complete from the current usage at each <TAB>.
+# Usage:
+#   source docopts.sh
+#   complete -F docopt_complete naval_fate.sh
+# Arguments, output as their placeholder like <file>, are completed with the
+# default bash completion: file names.
+docopt_complete() {
+    local script candidate
+    COMPREPLY=()
+    script=$(type -P "$1") || return 0
+    while IFS= read -r candidate ; do
+        if [[ $candidate == \<*\> || $candidate != -* && $candidate != *[a-z]* ]] ; then
+            compopt -o default 2> /dev/null || true
+        else
+            COMPREPLY+=("$candidate")
+        fi
+    done < <(docopts complete --from-script="$script" --cword="$COMP_CWORD" : "${COMP_WORDS[@]}")
+}
+
 ## main code
 # --auto : don't forget to pass "$@"
 # Usage: source docopts.sh --auto "$@"
@@ -0,0 +1,141 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_ast.go: the usage model as JSON for external tools, see: docopts ast
+//
+package main
+
+import (
+    "strings"
+)
+
+// Version of the JSON format of docopts ast, incremented on incompatible
+// changes. Adding fields is compatible.
+const Ast_version = 1
+
+// A node of a pattern: a leaf has the Name of a command, argument or option,
+// detailed in the Ast lists, a branch has Children.
+type Ast_node struct {
+    Type string `json:"type"`
+    Name string `json:"name,omitempty"`
+    Children []*Ast_node `json:"children,omitempty"`
+}
+
+// A command or a positional argument. Default is the value when absent from
+// argv: false or 0 for a command, null or [] for an argument.
+type Ast_element struct {
+    Name string `json:"name"`
+    Repeatable bool `json:"repeatable"`
+    Default interface{} `json:"default"`
+}
+
+// An option, described in an Options: section or only found in the patterns.
+// Short, Long, Argument and Description are null when absent. Default is the
+// value when absent: false or 0 for a flag, the [default: ...] value, or null,
+// for an option with argument, a list if it is repeatable.
+type Ast_option struct {
+    Name string `json:"name"`
+    Short *string `json:"short"`
+    Long *string `json:"long"`
+    Argcount int `json:"argcount"`
+    Argument *string `json:"argument"`
+    Default interface{} `json:"default"`
+    Repeatable bool `json:"repeatable"`
+    Description *string `json:"description"`
+    // false for a described option no pattern can match
+    In_usage bool `json:"in_usage"`
+}
+
+// The JSON document of docopts ast: each usage line is a pattern, and the
+// leaves are listed in order of declaration.
+type Ast struct {
+    Ast_version int `json:"ast_version"`
+    Prog string `json:"prog"`
+    Usage string `json:"usage"`
+    Patterns []*Ast_node `json:"patterns"`
+    Commands []Ast_element `json:"commands"`
+    Arguments []Ast_element `json:"arguments"`
+    Options []Ast_option `json:"options"`
+}
+
+func ast_string(s string) *string {
+    if s == "" {
+        return nil
+    }
+    return &s
+}
+
+func New_ast_node(p *Pattern) *Ast_node {
+    node := &Ast_node{Type: p.Type.String()}
+    if p.Is_leaf() {
+        node.Name = p.Name
+        return node
+    }
+    node.Children = []*Ast_node{}
+    for _, child := range p.Children {
+        node.Children = append(node.Children, New_ast_node(child))
+    }
+    return node
+}
+
+// Build the Ast of the usage model u.
+func New_ast(u *Usage_model) *Ast {
+    ast := &Ast{
+        Ast_version: Ast_version,
+        Prog: u.Prog,
+        Usage: u.Section,
+        Patterns: []*Ast_node{},
+        Commands: []Ast_element{},
+        Arguments: []Ast_element{},
+        Options: []Ast_option{},
+    }
+
+    for _, line := range u.Patterns() {
+        ast.Patterns = append(ast.Patterns, New_ast_node(line))
+    }
+
+    leaves := u.Leaves()
+    options := []*Pattern{}
+    for _, o := range u.Options {
+        if leaf := find_pattern(leaves, o); leaf != nil {
+            o = leaf
+        }
+        if find_pattern(options, o) == nil {
+            options = append(options, o)
+        }
+    }
+    for _, leaf := range leaves {
+        switch leaf.Type {
+        case Pattern_command:
+            ast.Commands = append(ast.Commands, Ast_element{leaf.Name, leaf.Repeatable(), leaf.Value})
+        case Pattern_argument:
+            ast.Arguments = append(ast.Arguments, Ast_element{leaf.Name, leaf.Repeatable(), leaf.Value})
+        case Pattern_option:
+            if find_pattern(options, leaf) == nil {
+                options = append(options, leaf)
+            }
+        }
+    }
+    for _, o := range options {
+        ast.Options = append(ast.Options, Ast_option{
+            Name: o.Name,
+            Short: ast_string(o.Short),
+            Long: ast_string(o.Long),
+            Argcount: o.Argcount,
+            Argument: ast_string(o.Arg),
+            Default: o.Value,
+            Repeatable: o.Repeatable(),
+            Description: ast_string(strings.Join(strings.Fields(o.Description), " ")),
+            In_usage: find_pattern(leaves, o) != nil,
+        })
+    }
+    return ast
+}
+
+// The Ast of the help message doc as indented JSON.
+func Ast_json(doc string) (string, error) {
+    u, err := Parse_usage(doc)
+    if err != nil {
+        return "", err
+    }
+    return To_json_indent(New_ast(u))
+}
@@ -0,0 +1,81 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_ast.go
+//
+package main
+
+import (
+    "testing"
+    "encoding/json"
+    "reflect"
+)
+
+func TestAst_json(t *testing.T) {
+    doc := `Usage:
+  prog [-v...] <x> [--out=F] [options]
+  prog run <y>...
+
+Options:
+  -q  Quiet.
+  --out=F  Output
+           file [default: a.txt].
+  --unused  Never matched.`
+    got, err := Ast_json(doc)
+    if err != nil {
+        t.Fatalf("Ast_json, error: %v", err)
+    }
+    var ast Ast
+    if err := json.Unmarshal([]byte(got), &ast); err != nil {
+        t.Fatalf("Ast_json, invalid JSON: %v\n%s", err, got)
+    }
+
+    if ast.Ast_version != Ast_version || ast.Prog != "prog" || len(ast.Patterns) != 2 {
+        t.Errorf("Ast_json, got version: %d, prog: %q, %d patterns", ast.Ast_version, ast.Prog, len(ast.Patterns))
+    }
+    run := ast.Patterns[1]
+    if run.Type != "required" || len(run.Children) != 2 || run.Children[0].Name != "run" ||
+        run.Children[1].Type != "one_or_more" || run.Children[1].Children[0].Name != "<y>" {
+        t.Errorf("Ast_json, second pattern: %+v", run)
+    }
+
+    expect_elements := []Ast_element{{"run", false, false}}
+    if !reflect.DeepEqual(ast.Commands, expect_elements) {
+        t.Errorf("Ast_json commands, got: %v, want: %v", ast.Commands, expect_elements)
+    }
+    expect_elements = []Ast_element{{"<x>", false, nil}, {"<y>", true, []interface{}{}}}
+    if !reflect.DeepEqual(ast.Arguments, expect_elements) {
+        t.Errorf("Ast_json arguments, got: %v, want: %v", ast.Arguments, expect_elements)
+    }
+
+    names := []string{}
+    for _, o := range ast.Options {
+        names = append(names, o.Name)
+    }
+    if expect := []string{"-q", "--out", "--unused", "-v"}; !reflect.DeepEqual(names, expect) {
+        t.Fatalf("Ast_json options, got: %v, want: %v", names, expect)
+    }
+    out := ast.Options[1]
+    if out.Short != nil || *out.Long != "--out" || out.Argcount != 1 || *out.Argument != "F" ||
+        out.Default != "a.txt" || out.Repeatable || *out.Description != "Output file [default: a.txt]." || !out.In_usage {
+        t.Errorf("Ast_json --out, got: %s", got)
+    }
+    // [options] matches any described option
+    if !ast.Options[2].In_usage {
+        t.Errorf("Ast_json --unused with [options], got not in_usage")
+    }
+    v := ast.Options[3]
+    if *v.Short != "-v" || v.Description != nil || v.Default != float64(0) || !v.Repeatable || !v.In_usage {
+        t.Errorf("Ast_json -v, got: %s", got)
+    }
+
+    got, _ = Ast_json("Usage: prog [-q]\n\nOptions:\n  -q  Quiet.\n  --unused  Never matched.")
+    ast = Ast{}
+    json.Unmarshal([]byte(got), &ast)
+    if len(ast.Options) != 2 || !ast.Options[0].In_usage || ast.Options[1].In_usage {
+        t.Errorf("Ast_json without [options], got: %s", got)
+    }
+
+    if _, err := Ast_json("Usage: prog ("); err == nil {
+        t.Errorf("Ast_json of a bad usage, no error")
+    }
+}
@@ -0,0 +1,340 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_check.go: static analysis of the references to the parsed arguments
+// in a script, see: docopts check
+//
+package main
+
+import (
+    "fmt"
+    "io/ioutil"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// How a script reads the parsed arguments: from the associative array Assoc,
+// with -A, or from global variables, prefixed by Prefix with -G.
+type Check_mode struct {
+    Assoc string
+    Prefix string
+}
+
+// Guess the mode from the docopts call of the script: docopt_auto_parse, also
+// called by 'source docopts.sh --auto', uses -A ARGS, otherwise the -A <name>
+// or -G <prefix> given to docopts, global variables without prefix if none.
+func Detect_check_mode(content string) Check_mode {
+    if Match(`(?m)^[^#\n]*(\bdocopt_auto_parse\b|\bdocopts\.sh\s+--auto\b)`, content) {
+        return Check_mode{Assoc: "ARGS"}
+    }
+    re := regexp.MustCompile(`(?m)^[^#\n]*\bdocopts\b[^\n]*?\s-([AG])\s*['"]?(\w+)`)
+    if m := re.FindStringSubmatch(content); m != nil {
+        if m[1] == "A" {
+            return Check_mode{Assoc: m[2]}
+        }
+        return Check_mode{Prefix: m[2]}
+    }
+    return Check_mode{}
+}
+
+// A variable or array element read by the script at Line and Column.
+type check_reference struct {
+    Line int
+    Column int
+    Name string
+    // the text of the reference, like ${ARGS[--output]} or $output
+    Text string
+}
+
+// Number of single character edits to change a into b.
+func edit_distance(a, b string) int {
+    ra, rb := []rune(a), []rune(b)
+    previous := make([]int, len(rb)+1)
+    for j := range previous {
+        previous[j] = j
+    }
+    for i := 1; i <= len(ra); i++ {
+        current := make([]int, len(rb)+1)
+        current[0] = i
+        for j := 1; j <= len(rb); j++ {
+            cost := 1
+            if ra[i-1] == rb[j-1] {
+                cost = 0
+            }
+            current[j] = min_int(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+        }
+        previous = current
+    }
+    return previous[len(rb)]
+}
+
+func min_int(first int, others ...int) int {
+    for _, n := range others {
+        if n < first {
+            first = n
+        }
+    }
+    return first
+}
+
+// The candidate closest to name, for a 'did you mean' hint: at most 2 edits
+// away and a third of its length, or the same once case, '-' and '_' are
+// ignored. Empty if none.
+func Check_closest(name string, candidates []string) string {
+    normalize := strings.NewReplacer("-", "", "_", "")
+    best, best_distance := "", 3
+    for _, c := range candidates {
+        distance := edit_distance(name, c)
+        if strings.EqualFold(normalize.Replace(name), normalize.Replace(c)) {
+            distance = 0
+        }
+        if distance < best_distance && distance*3 <= len([]rune(c)) {
+            best, best_distance = c, distance
+        }
+    }
+    return best
+}
+
+// Lines of the script which are not comments, with their 1-based number.
+func check_code_lines(content string) map[int]string {
+    lines := map[int]string{}
+    for i, line := range strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n") {
+        if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+            lines[i+1] = line
+        }
+    }
+    return lines
+}
+
+func check_sorted_lines(lines map[int]string) []int {
+    numbers := make([]int, 0, len(lines))
+    for n := range lines {
+        numbers = append(numbers, n)
+    }
+    sort.Ints(numbers)
+    return numbers
+}
+
+// The literal keys read from the associative array assoc: ${assoc[key]},
+// quoted or not, with the ,# or ,<index> suffix of repeatable arguments
+// removed, and the keys given to the docopts.sh helpers. dynamic is true if
+// the script reads keys it doesn't name, like ${!assoc[@]}.
+func check_assoc_references(lines map[int]string, assoc string) ([]check_reference, bool) {
+    name := regexp.QuoteMeta(assoc)
+    element := regexp.MustCompile(`\$\{` + name + `\[([^\]]*)\]`)
+    helper := regexp.MustCompile(`\bdocopt_get_(?:values|eval_array|raw_value)\s+` + name + `\s+(['"]?)([^\s'"();|&]+)`)
+    all := regexp.MustCompile(`\$\{!?` + name + `\[[@*]\]\}|\bdocopt_print_ARGS\b`)
+    suffix := regexp.MustCompile(`^(.*),(#|\d+|\$\w+|\$\{\w+\})$`)
+
+    references := []check_reference{}
+    dynamic := false
+    for _, n := range check_sorted_lines(lines) {
+        line := lines[n]
+        if all.MatchString(line) {
+            dynamic = true
+        }
+        for _, m := range element.FindAllStringSubmatchIndex(line, -1) {
+            key := strings.Trim(line[m[2]:m[3]], `'"`)
+            if s := suffix.FindStringSubmatch(key); s != nil {
+                key = strings.Trim(s[1], `'"`)
+            }
+            if key == "@" || key == "*" || strings.ContainsAny(key, "$`") {
+                continue
+            }
+            references = append(references, check_reference{n, m[0] + 1, key, line[m[0]:m[1]] + "}"})
+        }
+        for _, m := range helper.FindAllStringSubmatchIndex(line, -1) {
+            key := line[m[4]:m[5]]
+            if strings.ContainsAny(key, "$`") {
+                continue
+            }
+            references = append(references, check_reference{n, m[4] + 1, key, line[m[0]:m[1]]})
+        }
+    }
+    return references, dynamic
+}
+
+// The variables read by the script: $name, ${name...}, and the ones assigned,
+// declared or read by the script itself.
+func check_variable_references(lines map[int]string) ([]check_reference, map[string]bool) {
+    reference := regexp.MustCompile(`\$(?:\{[#!]?)?([A-Za-z_]\w*)`)
+    assignment := regexp.MustCompile(`(?:^|[\s;&|(])([A-Za-z_]\w*)(?:\[[^\]]*\])?\+?=`)
+    declaration := regexp.MustCompile(`\b(?:local|declare|typeset|readonly|export|read|for|mapfile|readarray)((?:\s+-\w+)*(?:\s+[A-Za-z_]\w*)+)`)
+
+    references := []check_reference{}
+    assigned := map[string]bool{}
+    for _, n := range check_sorted_lines(lines) {
+        line := lines[n]
+        for _, m := range reference.FindAllStringSubmatchIndex(line, -1) {
+            end := check_brace_end(line, m[0], m[1])
+            references = append(references, check_reference{n, m[0] + 1, line[m[2]:m[3]], line[m[0]:end]})
+        }
+        for _, m := range assignment.FindAllStringSubmatch(line, -1) {
+            assigned[m[1]] = true
+        }
+        for _, m := range declaration.FindAllStringSubmatch(line, -1) {
+            for _, word := range strings.Fields(m[1]) {
+                if !strings.HasPrefix(word, "-") {
+                    assigned[word] = true
+                }
+            }
+        }
+    }
+    return references, assigned
+}
+
+// End of the reference found at line[start:end]: after its closing brace for
+// ${...}, nested braces included, end for $name or an unclosed brace.
+func check_brace_end(line string, start, end int) int {
+    if !strings.HasPrefix(line[start:], "${") {
+        return end
+    }
+    depth := 1
+    for i := start + 2; i < len(line); i++ {
+        switch line[i] {
+        case '{':
+            depth++
+        case '}':
+            depth--
+            if depth == 0 {
+                return i + 1
+            }
+        }
+    }
+    return end
+}
+
+// Position of the first comment line of the script naming the option key, 0 if
+// not found.
+func check_option_line(content, key string) (int, int) {
+    re := regexp.MustCompile(`(^|[\s\[(|,])` + regexp.QuoteMeta(key) + `($|[\s\])|,=])`)
+    for i, line := range strings.Split(content, "\n") {
+        if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+            continue
+        }
+        if m := re.FindStringSubmatchIndex(line); m != nil {
+            return i + 1, m[3] + 1
+        }
+    }
+    return 0, 0
+}
+
+// Check the script content against its help message usage: references to
+// keys the usage doesn't define are errors, with a hint of the closest key,
+// and options never read are warnings. Global variables are mangled with the
+// settings of d. Without prefix, any variable can be read by a script: only
+// the ones close to a key and never assigned by the script are reported, as
+// warnings. Issues are sorted by position.
+func Check_script(content, usage string, mode Check_mode, d *Docopts) []Lint_issue {
+    u, err := Parse_usage(usage)
+    if err != nil {
+        return []Lint_issue{{Severity: Lint_error, Message: fmt.Sprintf("usage: %v", err)}}
+    }
+    issues := []Lint_issue{}
+    add := func(line, column int, severity Lint_severity, format string, a ...interface{}) {
+        issues = append(issues, Lint_issue{line, column, severity, fmt.Sprintf(format, a...)})
+    }
+
+    keys := []string{}
+    options := []string{}
+    for _, leaf := range u.Leaves() {
+        if leaf.Name == "-" || leaf.Name == "--" {
+            continue
+        }
+        keys = append(keys, leaf.Name)
+        if leaf.Type == Pattern_option && !Match(`^(-h|--help|--version)$`, leaf.Name) {
+            options = append(options, leaf.Name)
+        }
+    }
+    read := map[string]bool{}
+    lines := check_code_lines(content)
+
+    if mode.Assoc != "" {
+        references, dynamic := check_assoc_references(lines, mode.Assoc)
+        for _, r := range references {
+            if find_string(keys, r.Name) {
+                read[r.Name] = true
+                continue
+            }
+            message := fmt.Sprintf("%s: unknown key '%s'", r.Text, r.Name)
+            if closest := Check_closest(r.Name, keys); closest != "" {
+                message += fmt.Sprintf(", did you mean '%s'?", closest)
+            }
+            add(r.Line, r.Column, Lint_error, "%s", message)
+        }
+        if dynamic {
+            return Sort_issues(issues)
+        }
+    } else {
+        checked := *d
+        checked.Global_prefix = mode.Prefix
+        names, err := checked.Mangle_keys(keys)
+        if err != nil {
+            add(0, 0, Lint_error, "%v", err)
+            return issues
+        }
+        by_name := map[string]string{}
+        variables := []string{}
+        for _, key := range keys {
+            by_name[names[key]] = key
+            variables = append(variables, names[key])
+        }
+
+        references, assigned := check_variable_references(lines)
+        for _, r := range references {
+            if key, found := by_name[r.Name]; found {
+                read[key] = true
+                continue
+            }
+            prefixed := mode.Prefix != "" && strings.HasPrefix(r.Name, mode.Prefix+"_")
+            if !prefixed && (assigned[r.Name] || Reserved_name(r.Name, d.Shell) != "") {
+                continue
+            }
+            closest := Check_closest(r.Name, variables)
+            switch {
+            case prefixed && closest != "":
+                add(r.Line, r.Column, Lint_error, "%s: not defined by the usage, did you mean $%s (%s)?",
+                    r.Text, closest, by_name[closest])
+            case prefixed:
+                add(r.Line, r.Column, Lint_error, "%s: not defined by the usage", r.Text)
+            case closest != "":
+                add(r.Line, r.Column, Lint_warning, "%s: never assigned, did you mean $%s (%s)?",
+                    r.Text, closest, by_name[closest])
+            }
+        }
+    }
+
+    for _, key := range options {
+        if !read[key] {
+            line, column := check_option_line(content, key)
+            add(line, column, Lint_warning, "%s is never read", key)
+        }
+    }
+    return Sort_issues(issues)
+}
+
+func find_string(list []string, s string) bool {
+    for _, e := range list {
+        if e == s {
+            return true
+        }
+    }
+    return false
+}
+
+// Check the script at path, its usage is read as --from-script. The mode is
+// detected from the script if mode is empty, see: Detect_check_mode().
+func Check_script_file(path string, marker string, mode Check_mode, d *Docopts) []Lint_issue {
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        return []Lint_issue{{Severity: Lint_error, Message: fmt.Sprintf("%v", err)}}
+    }
+    usage, _ := Parse_script(string(content), marker, "")
+    if usage == "" {
+        return []Lint_issue{{Severity: Lint_error, Message: Script_usage_error(path, marker).Error()}}
+    }
+    if mode == (Check_mode{}) {
+        mode = Detect_check_mode(string(content))
+    }
+    return Check_script(string(content), usage, mode, d)
+}
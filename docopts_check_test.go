@@ -0,0 +1,135 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_check.go
+//
+package main
+
+import (
+    "testing"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+)
+
+var Check_usage = `Usage: c.sh [--output=FILE] [--dry-run] [--force] [-v] <src>...
+
+Options:
+  -o FILE, --output=FILE  Output.
+  --dry-run               Dry run.
+  --force                 Force.
+  -h --help               Help.`
+
+func TestDetect_check_mode(t *testing.T) {
+    tables := map[string]Check_mode{
+        "source docopts.sh --auto \"$@\"\n": {Assoc: "ARGS"},
+        "docopt_auto_parse \"$0\" \"$@\"\n": {Assoc: "ARGS"},
+        "eval \"$(docopts -A args -h \"$help\" : \"$@\")\"\n": {Assoc: "args"},
+        "eval \"$(docopts -V 1 -G 'opt' -h \"$help\" : \"$@\")\"\n": {Prefix: "opt"},
+        "# docopts -A commented\neval \"$(docopts -h \"$help\" : \"$@\")\"\n": {},
+    }
+    for script, expect := range tables {
+        if got := Detect_check_mode(script); got != expect {
+            t.Errorf("Detect_check_mode(%q), got: %v, want: %v", script, got, expect)
+        }
+    }
+}
+
+func TestCheck_closest(t *testing.T) {
+    keys := []string{"--output", "--dry-run", "<src>", "-v"}
+    tables := map[string]string{
+        "--ouput": "--output",
+        "--dry_run": "--dry-run",
+        "--DRYRUN": "--dry-run",
+        "-x": "",
+        "--verbose": "",
+    }
+    for name, expect := range tables {
+        if got := Check_closest(name, keys); got != expect {
+            t.Errorf("Check_closest(%q), got: %q, want: %q", name, got, expect)
+        }
+    }
+}
+
+func TestCheck_script(t *testing.T) {
+    d := &Docopts{Mangle_key: true, Reserved: "error"}
+    usage_lines := "# Usage: c.sh [--output=FILE] [--dry-run] [--force] [-v] <src>...\n" +
+        "#   -o FILE, --output=FILE  Output.\n"
+
+    script := usage_lines +
+        "echo \"${ARGS[--ouput]} ${ARGS['--dry-run']} ${ARGS[<src>,#]} ${ARGS[\"<src>,$i\"]}\"\n" +
+        "# ${ARGS[--commented]}\n" +
+        "values=$(docopt_get_values ARGS -x)\n" +
+        "echo ${ARGS[$key]} ${ARGS[-v]}\n"
+    expect := "c.sh:1:16: warning: --output is never read\n" +
+        "c.sh:1:44: warning: --force is never read\n" +
+        "c.sh:3:7: error: ${ARGS[--ouput]}: unknown key '--ouput', did you mean '--output'?\n" +
+        "c.sh:5:33: error: docopt_get_values ARGS -x: unknown key '-x'"
+    if got := lint_lines(Check_script(script, Check_usage, Check_mode{Assoc: "ARGS"}, d), "c.sh"); got != expect {
+        t.Errorf("Check_script -A, got:\n%s\nwant:\n%s", got, expect)
+    }
+
+    // all the keys are read
+    script = "for a in ${!ARGS[@]} ; do echo ${ARGS[--forc]}; done\n"
+    expect = "c.sh:1:32: error: ${ARGS[--forc]}: unknown key '--forc', did you mean '--force'?"
+    if got := lint_lines(Check_script(script, Check_usage, Check_mode{Assoc: "ARGS"}, d), "c.sh"); got != expect {
+        t.Errorf("Check_script -A with ${!ARGS[@]}, got:\n%s\nwant:\n%s", got, expect)
+    }
+
+    script = usage_lines +
+        "echo \"$opt_ouput $opt_dry_run ${opt_src[@]} ${#opt_force} $opt_v $opt_foo $HOME ${opt_bar:-${HOME}}\"\n"
+    expect = "c.sh:1:16: warning: --output is never read\n" +
+        "c.sh:3:7: error: $opt_ouput: not defined by the usage, did you mean $opt_output (--output)?\n" +
+        "c.sh:3:66: error: $opt_foo: not defined by the usage\n" +
+        "c.sh:3:81: error: ${opt_bar:-${HOME}}: not defined by the usage"
+    if got := lint_lines(Check_script(script, Check_usage, Check_mode{Prefix: "opt"}, d), "c.sh"); got != expect {
+        t.Errorf("Check_script -G, got:\n%s\nwant:\n%s", got, expect)
+    }
+
+    // without prefix, variables assigned by the script are not reported
+    script = usage_lines +
+        "dryrun=1\nlocal forced\nread -r -a outputs\n" +
+        "echo \"$ouput $dryrun $dry_run $forced $outputs $src $v $force $output $HOME $PATH ${ouput}\"\n"
+    expect = "c.sh:6:7: warning: $ouput: never assigned, did you mean $output (--output)?\n" +
+        "c.sh:6:83: warning: ${ouput}: never assigned, did you mean $output (--output)?"
+    if got := lint_lines(Check_script(script, Check_usage, Check_mode{}, d), "c.sh"); got != expect {
+        t.Errorf("Check_script global, got:\n%s\nwant:\n%s", got, expect)
+    }
+
+    expect = "c.sh: error: '--path' => 'path' would overwrite the shell variable PATH, use -G <prefix> or --reserved=prefix"
+    if got := lint_lines(Check_script("", "Usage: c.sh [--path=<p>]", Check_mode{}, d), "c.sh"); got != expect {
+        t.Errorf("Check_script with --path, got:\n%s\nwant:\n%s", got, expect)
+    }
+    expect = "c.sh: error: usage: unmatched '(', expected: ')' got: ''"
+    if got := lint_lines(Check_script("", "Usage: c.sh (", Check_mode{}, d), "c.sh"); got != expect {
+        t.Errorf("Check_script with a bad usage, got:\n%s\nwant:\n%s", got, expect)
+    }
+}
+
+func TestCheck_script_file(t *testing.T) {
+    dir, err := ioutil.TempDir("", "docopts_check")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    d := &Docopts{Mangle_key: true, Reserved: "error"}
+    path := filepath.Join(dir, "c.sh")
+    script := "#!/bin/bash\n# Usage: c.sh [--force]\nsource docopts.sh --auto \"$@\"\necho ${ARGS[--forse]}\n"
+    ioutil.WriteFile(path, []byte(script), 0644)
+    expect := path + ":2:16: warning: --force is never read\n" +
+        path + ":4:6: error: ${ARGS[--forse]}: unknown key '--forse', did you mean '--force'?"
+    if got := lint_lines(Check_script_file(path, "#", Check_mode{}, d), path); got != expect {
+        t.Errorf("Check_script_file, got:\n%s\nwant:\n%s", got, expect)
+    }
+
+    // the given mode takes precedence
+    expect = path + ":2:16: warning: --force is never read"
+    if got := lint_lines(Check_script_file(path, "#", Check_mode{Assoc: "args"}, d), path); got != expect {
+        t.Errorf("Check_script_file -A args, got:\n%s\nwant:\n%s", got, expect)
+    }
+
+    issues := Check_script_file(filepath.Join(dir, "none"), "#", Check_mode{}, d)
+    if len(issues) != 1 || issues[0].Severity != Lint_error {
+        t.Errorf("Check_script_file of a missing file, got: %v", issues)
+    }
+}
@@ -0,0 +1,1012 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_completion.go: bash, zsh and fish completion generated from the usage
+// model, see: --generate-completion
+//
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// A positional element of the usage, as completed: Kind is cmd for a command,
+// file or dir for an argument completed with file or directory names, arg for
+// any other argument.
+type Completion_word struct {
+    Kind string
+    Name string
+}
+
+// Encoded as kind:name: cmd:ship, file:<file>
+func (w Completion_word) String() string {
+    return w.Kind + ":" + w.Name
+}
+
+// The branches of the pattern tree from the root down to a leaf, with the
+// index of the child taken in each.
+type completion_path struct {
+    branches []*Pattern
+    index []int
+}
+
+func (path completion_path) child(p *Pattern, i int) completion_path {
+    return completion_path{
+        branches: append(append([]*Pattern{}, path.branches...), p),
+        index: append(append([]int{}, path.index...), i),
+    }
+}
+
+// Two leaves can be given together, unless they are in different children of
+// an either which is not repeated by a one or more above it.
+func (path completion_path) compatible(other completion_path) bool {
+    for i := 0; i < len(path.index) && i < len(other.index); i++ {
+        if path.index[i] == other.index[i] {
+            continue
+        }
+        if path.branches[i].Type != Pattern_either {
+            return true
+        }
+        for _, p := range path.branches[:i] {
+            if p.Type == Pattern_one_or_more {
+                return true
+            }
+        }
+        return false
+    }
+    return true
+}
+
+// A state of the completion: Word is the positional element just matched,
+// Next the states matching the word which can follow it.
+type Completion_state struct {
+    Word Completion_word
+    Next []int
+    path completion_path
+}
+
+// What a completion needs from the usage: the options, in order of
+// declaration, and a state machine of the positional elements built from the
+// pattern tree. States[0] is the start, each other state is a positional
+// element of the usage: the machine grows with the usage, not with the
+// combinations of its optional elements. Options are not in the states, they
+// may appear anywhere.
+// Excludes holds, by option name, the options given as alternatives of it in a
+// usage line, like --moored and --drifting in [--moored|--drifting].
+type Completion_model struct {
+    Prog string
+    Options []*Pattern
+    States []Completion_state
+    Excludes map[string][]*Pattern
+    // where each option is in the pattern tree, by name
+    option_paths map[string][]completion_path
+}
+
+func New_completion_model(u *Usage_model) *Completion_model {
+    options := append(u.Pattern.Flat(Pattern_option), u.Options...)
+    m := &Completion_model{
+        Prog: u.Prog,
+        Options: unique_patterns(options),
+        States: []Completion_state{{}},
+        Excludes: map[string][]*Pattern{},
+        option_paths: map[string][]completion_path{},
+    }
+    m.States[0].Next = m.completion_states(u.Pattern, completion_path{}).first
+
+    // the usage lines are alternatives too, but options of different lines
+    // are not meant to exclude each other
+    lines := u.Pattern.Children
+    if len(lines) == 1 && lines[0].Type == Pattern_either {
+        lines = lines[0].Children
+    }
+    for _, line := range lines {
+        completion_excludes(line, m.Excludes)
+    }
+    return m
+}
+
+func completion_excludes(p *Pattern, excludes map[string][]*Pattern) {
+    if p.Type == Pattern_either {
+        for i, child := range p.Children {
+            options := child.Flat(Pattern_option)
+            for _, o := range options {
+                for j, other := range p.Children {
+                    if i == j {
+                        continue
+                    }
+                    for _, e := range other.Flat(Pattern_option) {
+                        if find_pattern(options, e) == nil && find_pattern(excludes[o.Name], e) == nil {
+                            excludes[o.Name] = append(excludes[o.Name], e)
+                        }
+                    }
+                }
+            }
+        }
+    }
+    for _, child := range p.Children {
+        completion_excludes(child, excludes)
+    }
+}
+
+// Kind of completion for an argument or an option argument, from its name split
+// into words: file for <file>, FILE, <path>, <infile> or OUTPUT_FILE, dir
+// for <dir> or DIRECTORY, arg otherwise.
+func Completion_kind(name string) string {
+    for _, word := range regexp.MustCompile(`[^a-z0-9]+`).Split(strings.ToLower(name), -1) {
+        if Match(`^(dir|directory|folder)s?$`, word) {
+            return "dir"
+        }
+        if Match(`^(file|filename|path|infile|outfile)s?$`, word) {
+            return "file"
+        }
+    }
+    return "arg"
+}
+
+// The name completed for an option: the long name if any.
+func Completion_option_name(o *Pattern) string {
+    if o.Long != "" {
+        return o.Long
+    }
+    return o.Short
+}
+
+// Short and long names of an option, only those defined.
+func Completion_option_names(o *Pattern) []string {
+    names := []string{}
+    for _, n := range []string{o.Short, o.Long} {
+        if n != "" {
+            names = append(names, n)
+        }
+    }
+    return names
+}
+
+// An option can be given many times if it counts or accumulates values.
+func Completion_option_repeat(o *Pattern) bool {
+    return o.Repeatable()
+}
+
+// The option description of the Options: section on a single line, with its
+// [default: ...].
+func Completion_description(o *Pattern) string {
+    return strings.Join(strings.Fields(o.Description), " ")
+}
+
+// docopt options can't have an optional argument: a bare --generate-completion,
+// in docopts's own arguments before ':', is --generate-completion=bash.
+func Generate_completion_argv(argv []string) []string {
+    result := append([]string{}, argv...)
+    for i, arg := range result {
+        if arg == ":" {
+            break
+        }
+        if arg != "--generate-completion" {
+            continue
+        }
+        if i + 1 < len(result) && Match(`^(bash|zsh|fish)$`, result[i+1]) {
+            continue
+        }
+        result[i] = "--generate-completion=bash"
+    }
+    return result
+}
+
+// The states of a pattern: first can match its first positional word, last
+// its last one, nullable if it can match no positional word at all.
+type completion_fragment struct {
+    first []int
+    last []int
+    nullable bool
+}
+
+// Add the states of the positional elements of p, found at path in the
+// pattern tree, and link them in the order they can be given.
+func (m *Completion_model) completion_states(p *Pattern, path completion_path) completion_fragment {
+    switch p.Type {
+    case Pattern_option:
+        m.option_paths[p.Name] = append(m.option_paths[p.Name], path)
+        return completion_fragment{nullable: true}
+    case Pattern_command, Pattern_argument:
+        word := Completion_word{Kind: "cmd", Name: p.Name}
+        if p.Type == Pattern_argument {
+            word.Kind = Completion_kind(p.Name)
+        }
+        m.States = append(m.States, Completion_state{Word: word, path: path})
+        s := len(m.States) - 1
+        return completion_fragment{first: []int{s}, last: []int{s}}
+    }
+
+    f := completion_fragment{nullable: p.Type != Pattern_either}
+    for i, child := range p.Children {
+        c := m.completion_states(child, path.child(p, i))
+        if p.Type == Pattern_either {
+            f.first = append(f.first, c.first...)
+            f.last = append(f.last, c.last...)
+            f.nullable = f.nullable || c.nullable
+            continue
+        }
+        if p.Type == Pattern_optional || p.Type == Pattern_options_shortcut {
+            c.nullable = true
+        }
+        m.link(f.last, c.first)
+        if f.nullable {
+            f.first = append(f.first, c.first...)
+        }
+        if c.nullable {
+            f.last = append(f.last, c.last...)
+        } else {
+            f.last = c.last
+        }
+        f.nullable = f.nullable && c.nullable
+    }
+    if p.Type == Pattern_one_or_more {
+        m.link(f.last, f.first)
+    }
+    return f
+}
+
+// Each state of from can be followed by each state of to.
+func (m *Completion_model) link(from []int, to []int) {
+    for _, f := range from {
+        for _, t := range to {
+            if !has_state(m.States[f].Next, t) {
+                m.States[f].Next = append(m.States[f].Next, t)
+            }
+        }
+    }
+}
+
+func has_state(states []int, s int) bool {
+    for _, e := range states {
+        if e == s {
+            return true
+        }
+    }
+    return false
+}
+
+// The states as the shell scripts read them: the element of each state, empty
+// for the start, and the states which can follow it separated by a space.
+func (m *Completion_model) Script_states() (elements []string, next []string) {
+    for i, s := range m.States {
+        element := ""
+        if i > 0 {
+            element = s.Word.String()
+        }
+        following := make([]string, len(s.Next))
+        for j, n := range s.Next {
+            following[j] = fmt.Sprint(n)
+        }
+        elements = append(elements, element)
+        next = append(next, strings.Join(following, " "))
+    }
+    return elements, next
+}
+
+// The states as bash and zsh array elements, one per line.
+func shell_states(m *Completion_model) (elements string, next_states string) {
+    var e, n strings.Builder
+    encoded_elements, encoded_next := m.Script_states()
+    for i := range encoded_elements {
+        fmt.Fprintf(&e, "        %s\n", To_bash(encoded_elements[i]))
+        fmt.Fprintf(&n, "        %s\n", To_bash(encoded_next[i]))
+    }
+    return e.String(), n.String()
+}
+
+// Name of the bash completion function of prog: _naval_fate for naval_fate.py
+func Bash_completion_function(prog string) string {
+    name := strings.TrimSuffix(filepath.Base(prog), filepath.Ext(prog))
+    return "_" + regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(name, "_")
+}
+
+// The bash completion script, the model is embedded as data in a fixed
+// function body: {{name}}_option_arg gives the kind of argument of an option,
+// {{name}} walks the positional words typed so far through the states of the
+// usage and completes the elements that can come next.
+var Bash_completion_template = `# bash completion for {{prog}}, generated by docopts --generate-completion
+# source it, or install it in the bash-completion directory.
+
+# kind of the argument of the option $1: arg, file or dir, empty if none
+{{name}}_option_arg() {
+    case $1 in
+{{option_args}}        *) optarg= ;;
+    esac
+}
+
+{{name}}() {
+    # element of each state, none for the start, and the states following it
+    local elements=(
+{{elements}}    )
+    local next_states=(
+{{next_states}}    )
+    local options={{options}}
+    local words=() positional=() word optarg= pending= dashdash= prefix= i
+    local cur states next s n e name commands= files= dirs=
+    COMPREPLY=()
+    (( COMP_CWORD > 0 )) || return 0
+
+    # rejoin --option=value split by COMP_WORDBREAKS
+    for (( i = 1; i <= COMP_CWORD; i++ )); do
+        word=${COMP_WORDS[i]}
+        s=${#words[@]}
+        if (( s > 0 )) && [[ $word == = && ${words[s-1]} == --* && ${words[s-1]} != *=* ||
+                ${COMP_WORDS[i-1]} == = && ${words[s-1]} == --*= ]]; then
+            words[s-1]+=$word
+        else
+            words+=("$word")
+        fi
+    done
+    cur=${words[${#words[@]}-1]}
+
+    # positional words, skipping options and their argument
+    for word in "${words[@]:0:${#words[@]}-1}"; do
+        if [[ -n $pending ]]; then
+            pending=
+        elif [[ -z $dashdash && $word == -- ]]; then
+            dashdash=1
+        elif [[ -z $dashdash && $word == --?* ]]; then
+            {{name}}_option_arg "${word%%=*}"
+            [[ $word == *=* ]] || pending=$optarg
+        elif [[ -z $dashdash && $word == -?* ]]; then
+            for (( i = 1; i < ${#word}; i++ )); do
+                {{name}}_option_arg "-${word:i:1}"
+                if [[ -n $optarg ]]; then
+                    (( i == ${#word} - 1 )) && pending=$optarg
+                    break
+                fi
+            done
+        else
+            positional+=("$word")
+        fi
+    done
+
+    # argument of an option
+    if [[ -z $pending && -z $dashdash && $cur == --*=* ]]; then
+        {{name}}_option_arg "${cur%%=*}"
+        pending=$optarg
+        [[ $COMP_WORDBREAKS == *=* ]] || prefix=${cur%%=*}=
+        cur=${cur#*=}
+    fi
+    case $pending in
+        file)
+            compopt -o filenames 2> /dev/null
+            mapfile -t COMPREPLY < <(compgen -f -P "$prefix" -- "$cur")
+            return 0 ;;
+        dir)
+            compopt -o filenames 2> /dev/null
+            mapfile -t COMPREPLY < <(compgen -d -P "$prefix" -- "$cur")
+            return 0 ;;
+        ?*)
+            return 0 ;;
+    esac
+
+    if [[ -z $dashdash && $cur == -* ]]; then
+        mapfile -t COMPREPLY < <(compgen -W "$options" -- "$cur")
+        return 0
+    fi
+
+    # states reached by the positional words, then the elements following them
+    states=0
+    for word in "${positional[@]}"; do
+        next=
+        for s in $states; do
+            for n in ${next_states[s]}; do
+                e=${elements[n]}
+                [[ $e == cmd:* && ${e#cmd:} != "$word" ]] && continue
+                [[ " $next " == *" $n "* ]] || next+=" $n"
+            done
+        done
+        states=$next
+    done
+    for s in $states; do
+        for n in ${next_states[s]}; do
+            e=${elements[n]}
+            name=${e#*:}
+            case $e in
+                cmd:*) [[ " $commands " == *" $name "* ]] || commands+=" $name" ;;
+                file:*) files=1 ;;
+                dir:*) dirs=1 ;;
+            esac
+        done
+    done
+
+    mapfile -t COMPREPLY < <(compgen -W "$commands" -- "$cur")
+    if [[ -n $files || -n $dirs ]]; then
+        compopt -o filenames 2> /dev/null
+        if [[ -n $files ]]; then
+            mapfile -t -O ${#COMPREPLY[@]} COMPREPLY < <(compgen -f -- "$cur")
+        else
+            mapfile -t -O ${#COMPREPLY[@]} COMPREPLY < <(compgen -d -- "$cur")
+        fi
+    fi
+    return 0
+}
+
+complete -F {{name}} {{prog}}
+`
+
+// Outputs the bash completion script of the usage model: a self-contained
+// function registered with 'complete -F' for the program name.
+func Print_bash_completion(m *Completion_model) {
+    prog := Auto_quote(filepath.Base(m.Prog))
+
+    var option_args strings.Builder
+    long_names := make([]string, len(m.Options))
+    for i, o := range m.Options {
+        long_names[i] = Completion_option_name(o)
+        if o.Argcount == 0 {
+            continue
+        }
+        names := Completion_option_names(o)
+        for i, n := range names {
+            names[i] = Auto_quote(n)
+        }
+        fmt.Fprintf(&option_args, "        %s) optarg=%s ;;\n", strings.Join(names, "|"), Completion_kind(o.Arg))
+    }
+
+    elements, next_states := shell_states(m)
+    replacer := strings.NewReplacer(
+        "{{prog}}", prog,
+        "{{name}}", Bash_completion_function(m.Prog),
+        "{{option_args}}", option_args.String(),
+        "{{elements}}", elements,
+        "{{next_states}}", next_states,
+        "{{options}}", To_bash(strings.Join(long_names, " ")),
+    )
+    fmt.Fprint(out, replacer.Replace(Bash_completion_template))
+}
+
+// The zsh completion script: options are completed by _arguments, with their
+// description and exclusions, {{name}}_positional completes the positional
+// elements as the bash completion does. Installed as {{name}} in $fpath it is
+// autoloaded, sourced it registers itself with compdef.
+var Zsh_completion_template = `#compdef {{prog}}
+# zsh completion for {{prog}}, generated by docopts --generate-completion=zsh
+# install it as {{name}} in a directory of $fpath, or source it.
+
+# complete the elements following the positional words typed so far
+{{name}}_positional() {
+    emulate -L zsh
+    local -a elements next_states positional states next commands
+    local -A optarg
+    local -a expl
+    local word pending= dashdash= o i s n e name files= dirs=
+    # element of each state, none for the start, and the states following it,
+    # states are numbered from 0
+    elements=(
+{{elements}}    )
+    next_states=(
+{{next_states}}    )
+    # kind of the argument of the options taking one: arg, file or dir
+    optarg=(
+{{option_args}}    )
+
+    # positional words, skipping options and their argument
+    for word in "${(@)words[2,CURRENT-1]}"; do
+        if [[ -n $pending ]]; then
+            pending=
+        elif [[ -z $dashdash && $word == -- ]]; then
+            dashdash=1
+        elif [[ -z $dashdash && $word == --?* ]]; then
+            [[ $word == *=* ]] || pending=${optarg[$word]}
+        elif [[ -z $dashdash && $word == -?* ]]; then
+            for (( i = 2; i <= $#word; i++ )); do
+                o=-$word[i]
+                if [[ -n ${optarg[$o]} ]]; then
+                    (( i == $#word )) && pending=${optarg[$o]}
+                    break
+                fi
+            done
+        else
+            positional+=("$word")
+        fi
+    done
+
+    # states reached by the positional words, then the elements following them
+    states=(0)
+    for word in "${positional[@]}"; do
+        next=()
+        for s in "${states[@]}"; do
+            for n in ${=next_states[s+1]}; do
+                e=$elements[n+1]
+                [[ $e == cmd:* && ${e#cmd:} != "$word" ]] && continue
+                next+=($n)
+            done
+        done
+        states=(${(u)next})
+    done
+    for s in "${states[@]}"; do
+        for n in ${=next_states[s+1]}; do
+            e=$elements[n+1]
+            name=${e#*:}
+            case $e in
+                cmd:*) commands+=($name) ;;
+                file:*) files=1 ;;
+                dir:*) dirs=1 ;;
+            esac
+        done
+    done
+
+    local ret=1
+    commands=(${(u)commands})
+    if (( $#commands )); then
+        _wanted commands expl command compadd -a commands && ret=0
+    fi
+    if [[ -n $files ]]; then
+        _files && ret=0
+    elif [[ -n $dirs ]]; then
+        _files -/ && ret=0
+    fi
+    return ret
+}
+
+{{name}}() {
+    local context state state_descr line
+    typeset -A opt_args
+    _arguments -s -S \
+{{specs}}        '*: :->positional' && return 0
+    case $state in
+        positional) {{name}}_positional ;;
+    esac
+}
+
+if [[ $funcstack[1] == {{name}} ]]; then
+    {{name}} "$@"
+else
+    compdef {{name}} {{prog}}
+fi
+`
+
+// Escape a zsh _arguments option description, given between brackets.
+func Zsh_description_quote(s string) string {
+    return strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
+
+// The _arguments spec of an option, like:
+// '(-o --output)'{-o+,--output=}'[Output file.]:FILE:_files'
+func Zsh_option_spec(o *Pattern, excludes []*Pattern) string {
+    names := Completion_option_names(o)
+    exclusions := []string{}
+    if len(names) > 1 && !Completion_option_repeat(o) {
+        exclusions = append(exclusions, names...)
+    }
+    for _, e := range excludes {
+        exclusions = append(exclusions, Completion_option_names(e)...)
+    }
+
+    var head string
+    if len(exclusions) > 0 {
+        head = "(" + strings.Join(exclusions, " ") + ")"
+    }
+    if Completion_option_repeat(o) {
+        head += "*"
+    }
+
+    for i, n := range names {
+        if o.Argcount > 0 && strings.HasPrefix(n, "--") {
+            names[i] = n + "="
+        } else if o.Argcount > 0 {
+            names[i] = n + "+"
+        }
+    }
+
+    var tail string
+    if description := Completion_description(o); description != "" {
+        tail = "[" + Zsh_description_quote(description) + "]"
+    }
+    if o.Argcount > 0 {
+        message := strings.Replace(o.Arg, ":", " ", -1)
+        if message == "" {
+            message = "value"
+        }
+        action := map[string]string{"file": "_files", "dir": "_files -/", "arg": ""}[Completion_kind(o.Arg)]
+        tail += ":" + message + ":" + action
+    }
+
+    if len(names) == 1 {
+        return To_bash(head + names[0] + tail)
+    }
+    for i, n := range names {
+        names[i] = Auto_quote(n)
+    }
+    spec := To_bash(head) + "{" + strings.Join(names, ",") + "}"
+    if tail != "" {
+        spec += To_bash(tail)
+    }
+    return spec
+}
+
+// Outputs the zsh completion script of the usage model.
+func Print_zsh_completion(m *Completion_model) {
+    prog := Auto_quote(filepath.Base(m.Prog))
+
+    var option_args, specs strings.Builder
+    for _, o := range m.Options {
+        fmt.Fprintf(&specs, "        %s \\\n", Zsh_option_spec(o, m.Excludes[o.Name]))
+        if o.Argcount == 0 {
+            continue
+        }
+        for _, n := range Completion_option_names(o) {
+            fmt.Fprintf(&option_args, "        %s %s\n", Auto_quote(n), Completion_kind(o.Arg))
+        }
+    }
+
+    elements, next_states := shell_states(m)
+    replacer := strings.NewReplacer(
+        "{{prog}}", prog,
+        "{{name}}", Bash_completion_function(m.Prog),
+        "{{option_args}}", option_args.String(),
+        "{{elements}}", elements,
+        "{{next_states}}", next_states,
+        "{{specs}}", specs.String(),
+    )
+    fmt.Fprint(out, replacer.Replace(Zsh_completion_template))
+}
+
+// The fish completion script: a complete command per option, with its
+// description and the options excluding it as condition,
+// {{name}}_positional prints the positional elements that can come next,
+// like the bash completion does.
+var Fish_completion_template = `# fish completion for {{prog}}, generated by docopts --generate-completion=fish
+# install it as {{prog}}.fish in ~/.config/fish/completions, or source it.
+
+# print the names of the elements of kind $argv[1]: cmd, file or dir, following
+# the positional words typed so far, fails if none
+function {{name}}_positional
+    # element of each state, none for the start, and the states following it,
+    # states are numbered from 0
+    set -l elements \
+{{elements}}
+    set -l next_states \
+{{next_states}}
+    set -l arg_options (string split -n -- ' ' {{arg_options}})
+    set -l words (commandline -opc)
+    set -e words[1]
+    set -l positional
+    set -l pending
+    set -l dashdash
+    set -l found
+
+    # positional words, skipping options and their argument
+    for word in $words
+        if test -n "$pending"
+            set pending
+        else if test -z "$dashdash" -a "$word" = --
+            set dashdash 1
+        else if test -z "$dashdash"; and string match -q -- '--?*' $word
+            if not string match -q -- '*=*' $word; and contains -- $word $arg_options
+                set pending 1
+            end
+        else if test -z "$dashdash"; and string match -q -- '-?*' $word
+            set -l length (string length -- $word)
+            for i in (seq 2 $length)
+                if contains -- -(string sub -s $i -l 1 -- $word) $arg_options
+                    test $i -eq $length; and set pending 1
+                    break
+                end
+            end
+        else
+            set -a positional $word
+        end
+    end
+
+    # states reached by the positional words, then the elements following them
+    set -l states 0
+    for word in $positional
+        set -l next
+        for s in $states
+            for n in (string split -n -- ' ' $next_states[(math $s + 1)])
+                set -l e $elements[(math $n + 1)]
+                set -l name (string replace -r -- '^[a-z]+:' '' $e)
+                if string match -q -- 'cmd:*' $e; and test "$name" != "$word"
+                    continue
+                end
+                contains -- $n $next; or set -a next $n
+            end
+        end
+        set states $next
+    end
+    for s in $states
+        for n in (string split -n -- ' ' $next_states[(math $s + 1)])
+            set -l e $elements[(math $n + 1)]
+            if string match -q -- "$argv[1]:*" $e
+                set -l name (string replace -r -- '^[a-z]+:' '' $e)
+                contains -- $name $found; or set -a found $name
+            end
+        end
+    end
+
+    printf '%s\n' $found
+    test (count $found) -gt 0
+end
+
+complete -c {{prog}} -f
+complete -c {{prog}} -n '{{name}}_positional cmd > /dev/null' -a '({{name}}_positional cmd)'
+complete -c {{prog}} -n '{{name}}_positional file > /dev/null' -F
+complete -c {{prog}} -n '{{name}}_positional dir > /dev/null' -a '(__fish_complete_directories (commandline -ct))'
+{{options}}`
+
+// The complete command of an option, like:
+// complete -c naval_fate -s o -l output -r -F -d 'Output file.'
+func Fish_option_complete(prog string, o *Pattern, excludes []*Pattern) string {
+    line := "complete -c " + prog
+    if len(excludes) > 0 {
+        seen := []string{}
+        for _, e := range excludes {
+            if e.Short != "" {
+                seen = append(seen, "-s " + strings.TrimPrefix(e.Short, "-"))
+            }
+            if e.Long != "" {
+                seen = append(seen, "-l " + strings.TrimPrefix(e.Long, "--"))
+            }
+        }
+        line += " -n '" + Fishquote("not __fish_seen_argument " + strings.Join(seen, " ")) + "'"
+    }
+    if o.Short != "" {
+        line += " -s " + Auto_quote(strings.TrimPrefix(o.Short, "-"))
+    }
+    if o.Long != "" {
+        line += " -l " + Auto_quote(strings.TrimPrefix(o.Long, "--"))
+    }
+    if o.Argcount > 0 {
+        switch Completion_kind(o.Arg) {
+        case "file":
+            line += " -r -F"
+        case "dir":
+            line += " -x -a '(__fish_complete_directories (commandline -ct))'"
+        default:
+            line += " -x"
+        }
+    }
+    if description := Completion_description(o); description != "" {
+        line += " -d '" + Fishquote(description) + "'"
+    }
+    return line
+}
+
+// Outputs the fish completion script of the usage model.
+func Print_fish_completion(m *Completion_model) {
+    prog := Auto_quote(filepath.Base(m.Prog))
+
+    var options strings.Builder
+    arg_options := []string{}
+    for _, o := range m.Options {
+        options.WriteString(Fish_option_complete(prog, o, m.Excludes[o.Name]) + "\n")
+        if o.Argcount > 0 {
+            arg_options = append(arg_options, Completion_option_names(o)...)
+        }
+    }
+
+    elements, next_states := m.Script_states()
+    for i := range elements {
+        elements[i] = "        '" + Fishquote(elements[i]) + "'"
+        next_states[i] = "        '" + Fishquote(next_states[i]) + "'"
+    }
+
+    replacer := strings.NewReplacer(
+        "{{prog}}", prog,
+        "{{name}}", "_" + Bash_completion_function(m.Prog),
+        "{{arg_options}}", "'" + Fishquote(strings.Join(arg_options, " ")) + "'",
+        "{{elements}}", strings.Join(elements, " \\\n"),
+        "{{next_states}}", strings.Join(next_states, " \\\n"),
+        "{{options}}", options.String(),
+    )
+    fmt.Fprint(out, replacer.Replace(Fish_completion_template))
+}
+
+// The option named name in argv: a short option, or a long option or its
+// unique prefix as docopt accepts it. nil if unknown.
+func (m *Completion_model) Option(name string) *Pattern {
+    if !strings.HasPrefix(name, "--") {
+        for _, o := range m.Options {
+            if o.Short == name {
+                return o
+            }
+        }
+        return nil
+    }
+    var found *Pattern
+    for _, o := range m.Options {
+        if o.Long == name {
+            return o
+        }
+        if o.Long != "" && strings.HasPrefix(o.Long, name) {
+            if found != nil {
+                return nil
+            }
+            found = o
+        }
+    }
+    return found
+}
+
+// An option can be given along a leaf at path if one of its occurrences in the
+// usage can.
+func (m *Completion_model) option_along(o *Pattern, path completion_path) bool {
+    for _, p := range m.option_paths[o.Name] {
+        if p.compatible(path) {
+            return true
+        }
+    }
+    return false
+}
+
+// The state s can be reached along all the options seen.
+func (m *Completion_model) usable(s int, seen []*Pattern) bool {
+    for _, o := range seen {
+        if !m.option_along(o, m.States[s].path) {
+            return false
+        }
+    }
+    return true
+}
+
+// The states reached from states by the positional word, same as the
+// completion scripts, skipping the elements which can't be given along the
+// options seen.
+func (m *Completion_model) Next(states []int, word string, seen []*Pattern) []int {
+    next := []int{}
+    for _, s := range states {
+        for _, n := range m.States[s].Next {
+            w := m.States[n].Word
+            if w.Kind == "cmd" && w.Name != word || has_state(next, n) || !m.usable(n, seen) {
+                continue
+            }
+            next = append(next, n)
+        }
+    }
+    return next
+}
+
+// An option can be offered if it can be given along all the options seen and
+// along the element of one of the states.
+func (m *Completion_model) option_allowed(o *Pattern, states []int, seen []*Pattern) bool {
+    for _, s := range seen {
+        along := false
+        for _, path := range m.option_paths[s.Name] {
+            along = along || m.option_along(o, path)
+        }
+        if !along {
+            return false
+        }
+    }
+    for _, s := range states {
+        if m.option_along(o, m.States[s].path) {
+            return true
+        }
+    }
+    return false
+}
+
+// Candidates for the word at index cword of words, words[0] being the program
+// name, as COMP_WORDS and COMP_CWORD in bash: the commands and options which
+// can come next and start with the word, and the placeholders of the arguments,
+// like <file>, whatever the word. Options are offered if the word starts with
+// '-' or if no positional element can come next. With options_first, options
+// are not recognized after the first positional word.
+func (m *Completion_model) Complete(words []string, cword int, options_first bool) []string {
+    if cword < 1 {
+        return []string{}
+    }
+    if cword > len(words) {
+        cword = len(words)
+    }
+    cur := ""
+    if cword < len(words) {
+        cur = words[cword]
+    }
+
+    // positional words, skipping options and their argument
+    positional := []string{}
+    seen := []*Pattern{}
+    var pending *Pattern
+    dashdash := false
+    for _, word := range words[1:cword] {
+        switch {
+        case pending != nil:
+            pending = nil
+        case dashdash || options_first && len(positional) > 0 || word == "-" || !strings.HasPrefix(word, "-"):
+            positional = append(positional, word)
+        case word == "--":
+            dashdash = true
+        case strings.HasPrefix(word, "--"):
+            name, eq, _ := partition(word, "=")
+            if o := m.Option(name); o != nil {
+                seen = append(seen, o)
+                if o.Argcount > 0 && eq == "" {
+                    pending = o
+                }
+            }
+        default:
+            for i := 1; i < len(word); i++ {
+                o := m.Option("-" + word[i:i+1])
+                if o == nil {
+                    continue
+                }
+                seen = append(seen, o)
+                if o.Argcount > 0 {
+                    if i == len(word) - 1 {
+                        pending = o
+                    }
+                    break
+                }
+            }
+        }
+    }
+    with_options := !dashdash && !(options_first && len(positional) > 0)
+
+    // argument of an option
+    if pending == nil && with_options && strings.HasPrefix(cur, "--") {
+        name, eq, _ := partition(cur, "=")
+        if o := m.Option(name); o != nil && o.Argcount > 0 && eq == "=" {
+            pending = o
+        }
+    }
+    if pending != nil {
+        if pending.Arg == "" {
+            return []string{}
+        }
+        return []string{pending.Arg}
+    }
+
+    candidates := []string{}
+    add := func(candidate string, filter bool) {
+        if filter && !strings.HasPrefix(candidate, cur) {
+            return
+        }
+        for _, c := range candidates {
+            if c == candidate {
+                return
+            }
+        }
+        candidates = append(candidates, candidate)
+    }
+
+    // states matching the words typed so far
+    states := []int{}
+    if m.usable(0, seen) {
+        states = append(states, 0)
+    }
+    for _, word := range positional {
+        states = m.Next(states, word, seen)
+    }
+    following := []Completion_word{}
+    for _, s := range states {
+        for _, n := range m.States[s].Next {
+            if m.usable(n, seen) {
+                following = append(following, m.States[n].Word)
+            }
+        }
+    }
+
+    if !with_options || !strings.HasPrefix(cur, "-") {
+        for _, w := range following {
+            add(w.Name, w.Kind == "cmd")
+        }
+        if !with_options || len(following) > 0 {
+            return candidates
+        }
+    }
+    for _, o := range m.Options {
+        if !m.option_allowed(o, states, seen) {
+            continue
+        }
+        if find_pattern(seen, o) != nil && !Completion_option_repeat(o) {
+            continue
+        }
+        excluded := false
+        for _, s := range seen {
+            if find_pattern(m.Excludes[s.Name], o) != nil {
+                excluded = true
+            }
+        }
+        if !excluded {
+            add(Completion_option_name(o), true)
+        }
+    }
+    return candidates
+}
@@ -0,0 +1,402 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_completion.go
+//
+package main
+
+import (
+    "testing"
+    "bytes"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+var Completion_usage = `Naval Fate.
+
+Usage:
+  naval_fate.py ship new <name>...
+  naval_fate.py ship <name> move <x> <y> [--speed=<kn>]
+  naval_fate.py mine (set|remove) <x> <y> [--moored|--drifting]
+  naval_fate.py load [options] <file>...
+  naval_fate.py -h | --help
+
+Options:
+  -h --help                 Show this screen.
+  --speed=<kn>              Speed in knots [default: 10].
+  --moored                  Moored (anchored) mine.
+  --drifting                Drifting mine.
+  -o FILE, --output=FILE    Output file.
+  --dest=<dir>              Destination.
+`
+
+func TestCompletion_kind(t *testing.T) {
+    tables := map[string]string{
+        "<file>": "file",
+        "FILE": "file",
+        "<path>": "file",
+        "<infile>": "file",
+        "OUTPUT_FILE": "file",
+        "<files>": "file",
+        "<dir>": "dir",
+        "DIRECTORY": "dir",
+        "<name>": "arg",
+        "<profile>": "arg",
+        "": "arg",
+    }
+    for name, expect := range tables {
+        if kind := Completion_kind(name); kind != expect {
+            t.Errorf("Completion_kind for '%s', got: '%s', want: '%s'", name, kind, expect)
+        }
+    }
+}
+
+func TestNew_completion_model(t *testing.T) {
+    u, err := Parse_usage(Completion_usage)
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    m := New_completion_model(u)
+
+    states := make([]string, len(m.States))
+    for i, s := range m.States {
+        states[i] = fmt.Sprintf("%s %v", s.Word, s.Next)
+    }
+    expect := []string{
+        ": [1 4 9 14]",
+        "cmd:ship [2]",
+        "cmd:new [3]",
+        "arg:<name> [3]",
+        "cmd:ship [5]",
+        "arg:<name> [6]",
+        "cmd:move [7]",
+        "arg:<x> [8]",
+        "arg:<y> []",
+        "cmd:mine [10 11]",
+        "cmd:set [12]",
+        "cmd:remove [12]",
+        "arg:<x> [13]",
+        "arg:<y> []",
+        "cmd:load [15]",
+        "file:<file> [15]",
+    }
+    if strings.Join(states, "\n") != strings.Join(expect, "\n") {
+        t.Errorf("New_completion_model States\ngot: %v\nwant: %v", states, expect)
+    }
+
+    names := []string{}
+    for _, o := range m.Options {
+        names = append(names, fmt.Sprintf("%s=%s", Completion_option_name(o), o.Arg))
+    }
+    expect = []string{"--speed=<kn>", "--moored=", "--drifting=", "--output=FILE", "--dest=<dir>", "--help="}
+    if strings.Join(names, " ") != strings.Join(expect, " ") {
+        t.Errorf("New_completion_model Options\ngot: %v\nwant: %v", names, expect)
+    }
+}
+
+// the states grow with the usage, not with the combinations of optional
+// elements: 2^40 of them here
+func TestNew_completion_model_optional(t *testing.T) {
+    usage := "Usage: prog"
+    for i := 0; i < 40; i++ {
+        usage += fmt.Sprintf(" [(c%d <a%d>)]", i, i)
+    }
+    u, err := Parse_usage(usage + " [--end]")
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    m := New_completion_model(u)
+    if len(m.States) != 81 {
+        t.Errorf("New_completion_model States, got: %d states, want: 81", len(m.States))
+    }
+
+    words := []string{"prog", "c3", "x", "c39", "y", ""}
+    if candidates := m.Complete(words, 5, false); fmt.Sprint(candidates) != "[--end]" {
+        t.Errorf("Complete for %q, got: %q, want: [--end]", words, candidates)
+    }
+    words = []string{"prog", "c37", "x", "c"}
+    if candidates := m.Complete(words, 3, false); fmt.Sprint(candidates) != "[c38 c39]" {
+        t.Errorf("Complete for %q, got: %q, want: [c38 c39]", words, candidates)
+    }
+}
+
+func TestBash_completion_function(t *testing.T) {
+    tables := map[string]string{
+        "naval_fate.py": "_naval_fate",
+        "./bin/my-prog": "_my_prog",
+        "prog": "_prog",
+    }
+    for prog, expect := range tables {
+        if name := Bash_completion_function(prog); name != expect {
+            t.Errorf("Bash_completion_function for '%s', got: '%s', want: '%s'", prog, name, expect)
+        }
+    }
+}
+
+// source the generated completion in bash and complete the words, the last
+// one being the word under the cursor
+func TestPrint_bash_completion(t *testing.T) {
+    bash := round_trip_bash(t)
+
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    u, err := Parse_usage(Completion_usage)
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    Print_bash_completion(New_completion_model(u))
+    script := out.(*bytes.Buffer).String()
+    if !strings.HasSuffix(script, "\ncomplete -F _naval_fate naval_fate.py\n") {
+        t.Errorf("Print_bash_completion must register _naval_fate, got:\n%s", script)
+    }
+
+    dir, err := ioutil.TempDir("", "docopts_completion")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    for _, f := range []string{"a.txt", "b.txt"} {
+        if err := ioutil.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := os.Mkdir(filepath.Join(dir, "a.d"), 0755); err != nil {
+        t.Fatal(err)
+    }
+
+    tables := []struct {
+        words []string
+        expect string
+    }{
+        {[]string{""}, "load mine ship"},
+        {[]string{"m"}, "mine"},
+        {[]string{"ship", ""}, "new"},
+        {[]string{"ship", "guy", ""}, "move"},
+        {[]string{"ship", "new", "guy", ""}, ""},
+        {[]string{"mine", ""}, "remove set"},
+        {[]string{"--moored", "mine", "set", "1", "2", ""}, ""},
+        {[]string{"--speed", "10", "ship", ""}, "new"},
+        {[]string{"--speed", ""}, ""},
+        {[]string{"load", ""}, "a.d a.txt b.txt"},
+        {[]string{"load", "a.txt", "b"}, "b.txt"},
+        {[]string{"load", "-o", "a"}, "a.d a.txt"},
+        {[]string{"load", "-xo", "a.txt", ""}, "a.d a.txt b.txt"},
+        {[]string{"load", "--output", "=", "b"}, "b.txt"},
+        {[]string{"load", "--dest", ""}, "a.d"},
+        {[]string{"--d"}, "--dest --drifting"},
+        {[]string{"load", "--", "-"}, ""},
+    }
+    for _, table := range tables {
+        cmd := exec.Command(bash, "--norc", "-c", `source /dev/stdin || exit
+COMP_WORDS=(naval_fate.py "$@")
+COMP_CWORD=$#
+_naval_fate
+printf '%s\n' "${COMPREPLY[@]}" | sort | tr '\n' ' '`, "bash")
+        cmd.Args = append(cmd.Args, table.words...)
+        cmd.Dir = dir
+        cmd.Stdin = strings.NewReader(script)
+        res, err := cmd.CombinedOutput()
+        if err != nil {
+            t.Fatalf("bash error: %v: %s", err, res)
+        }
+        if got := strings.TrimSpace(string(res)); got != table.expect {
+            t.Errorf("completion of %q\ngot: '%s'\nwant: '%s'", table.words, got, table.expect)
+        }
+    }
+}
+
+func TestCompletion_excludes(t *testing.T) {
+    u, err := Parse_usage(Completion_usage)
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    m := New_completion_model(u)
+
+    excludes := map[string]string{}
+    for name, list := range m.Excludes {
+        excludes[name] = fmt.Sprint(Completion_option_names(list[0]))
+        if len(list) != 1 {
+            t.Errorf("Excludes of '%s', got: %d options, want: 1", name, len(list))
+        }
+    }
+    expect := map[string]string{"--moored": "[--drifting]", "--drifting": "[--moored]"}
+    if fmt.Sprint(excludes) != fmt.Sprint(expect) {
+        t.Errorf("New_completion_model Excludes\ngot: %v\nwant: %v", excludes, expect)
+    }
+}
+
+func TestGenerate_completion_argv(t *testing.T) {
+    tables := []struct {
+        argv []string
+        expect []string
+    }{
+        {[]string{"--generate-completion", "-h", "usage: p"}, []string{"--generate-completion=bash", "-h", "usage: p"}},
+        {[]string{"--generate-completion", "zsh", "-h", "usage: p"}, []string{"--generate-completion", "zsh", "-h", "usage: p"}},
+        {[]string{"--generate-completion=fish"}, []string{"--generate-completion=fish"}},
+        {[]string{"-h", "usage: p <x>", ":", "--generate-completion"}, []string{"-h", "usage: p <x>", ":", "--generate-completion"}},
+    }
+    for _, table := range tables {
+        if argv := Generate_completion_argv(table.argv); fmt.Sprint(argv) != fmt.Sprint(table.expect) {
+            t.Errorf("Generate_completion_argv for %q\ngot: %q\nwant: %q", table.argv, argv, table.expect)
+        }
+    }
+}
+
+func TestZsh_option_spec(t *testing.T) {
+    u, err := Parse_usage(strings.Replace(Completion_usage, "load [options]", "load [-vv] [options]", 1) +
+        "  -v --verbose              Verbose [level].\n")
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    m := New_completion_model(u)
+
+    expect := map[string]string{
+        "--speed": `'--speed=[Speed in knots \[default: 10\].]:<kn>:'`,
+        "--moored": `'(--drifting)--moored[Moored (anchored) mine.]'`,
+        "--output": `'(-o --output)'{-o+,'--output='}'[Output file.]:FILE:_files'`,
+        "--dest": `'--dest=[Destination.]:<dir>:_files -/'`,
+        "--verbose": `'*'{-v,--verbose}'[Verbose \[level\].]'`,
+    }
+    for _, o := range m.Options {
+        want, ok := expect[o.Name]
+        if !ok {
+            continue
+        }
+        delete(expect, o.Name)
+        if spec := Zsh_option_spec(o, m.Excludes[o.Name]); spec != want {
+            t.Errorf("Zsh_option_spec for '%s'\ngot: %s\nwant: %s", o.Name, spec, want)
+        }
+    }
+    if len(expect) > 0 {
+        t.Errorf("Zsh_option_spec, options not found: %v", expect)
+    }
+}
+
+func TestFish_option_complete(t *testing.T) {
+    u, err := Parse_usage(Completion_usage)
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    m := New_completion_model(u)
+
+    expect := map[string]string{
+        "--speed": `complete -c p -l speed -x -d 'Speed in knots [default: 10].'`,
+        "--moored": `complete -c p -n 'not __fish_seen_argument -l drifting' -l moored -d 'Moored (anchored) mine.'`,
+        "--output": `complete -c p -s o -l output -r -F -d 'Output file.'`,
+        "--dest": `complete -c p -l dest -x -a '(__fish_complete_directories (commandline -ct))' -d 'Destination.'`,
+        "--help": `complete -c p -s h -l help -d 'Show this screen.'`,
+    }
+    for _, o := range m.Options {
+        want, ok := expect[o.Name]
+        if !ok {
+            continue
+        }
+        delete(expect, o.Name)
+        if line := Fish_option_complete("p", o, m.Excludes[o.Name]); line != want {
+            t.Errorf("Fish_option_complete for '%s'\ngot: %s\nwant: %s", o.Name, line, want)
+        }
+    }
+    if len(expect) > 0 {
+        t.Errorf("Fish_option_complete, options not found: %v", expect)
+    }
+}
+
+func TestPrint_zsh_fish_completion(t *testing.T) {
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    u, err := Parse_usage(Completion_usage)
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    m := New_completion_model(u)
+
+    Print_zsh_completion(m)
+    script := out.(*bytes.Buffer).String()
+    for _, expect := range []string{
+        "#compdef naval_fate.py\n",
+        "\n    elements=(\n        ''\n        'cmd:ship'\n",
+        "\n    next_states=(\n        '1 4 9 14'\n        '2'\n",
+        "\n        -o file\n        --output file\n",
+        "\n        '(-h --help)'{-h,--help}'[Show this screen.]' \\\n        '*: :->positional' && return 0\n",
+        "\n    compdef _naval_fate naval_fate.py\n",
+    } {
+        if !strings.Contains(script, expect) {
+            t.Errorf("Print_zsh_completion must contain %q, got:\n%s", expect, script)
+        }
+    }
+    out.(*bytes.Buffer).Reset()
+
+    Print_fish_completion(m)
+    script = out.(*bytes.Buffer).String()
+    for _, expect := range []string{
+        "\nfunction __naval_fate_positional\n",
+        "\n    set -l elements \\\n        '' \\\n        'cmd:ship' \\\n",
+        "\n        'file:<file>'\n    set -l next_states \\\n        '1 4 9 14' \\\n",
+        "\n        '15' \\\n        '15'\n",
+        "(string split -n -- ' ' '--speed -o --output --dest')",
+        "\ncomplete -c naval_fate.py -n '__naval_fate_positional cmd > /dev/null' -a '(__naval_fate_positional cmd)'\n",
+        "\ncomplete -c naval_fate.py -s h -l help -d 'Show this screen.'\n",
+    } {
+        if !strings.Contains(script, expect) {
+            t.Errorf("Print_fish_completion must contain %q, got:\n%s", expect, script)
+        }
+    }
+}
+
+func TestComplete(t *testing.T) {
+    u, err := Parse_usage(Completion_usage)
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    m := New_completion_model(u)
+
+    tables := []struct {
+        line string
+        options_first bool
+        expect string
+    }{
+        {"naval_fate.py |", false, "ship mine load"},
+        {"naval_fate.py s|", false, "ship"},
+        {"naval_fate.py ship |", false, "new <name>"},
+        {"naval_fate.py ship new |", false, "<name> move"},
+        {"naval_fate.py ship new boat |", false, "<name>"},
+        {"naval_fate.py ship boat move 1 2 |", false, "--speed"},
+        {"naval_fate.py ship boat move 1 2 --speed |", false, "<kn>"},
+        {"naval_fate.py ship boat move 1 2 --speed=|", false, "<kn>"},
+        {"naval_fate.py ship boat move 1 2 --speed 3 |", false, ""},
+        {"naval_fate.py ship boat move 1 2 --sp 3 -|", false, ""},
+        {"naval_fate.py -|", false, "--speed --moored --drifting --output --dest --help"},
+        {"naval_fate.py --moored |", false, "mine"},
+        {"naval_fate.py --moored -|", false, ""},
+        {"naval_fate.py mine set 1 2 --|", false, "--moored --drifting"},
+        {"naval_fate.py load -o |", false, "FILE"},
+        {"naval_fate.py load -xo a |", false, "<file>"},
+        {"naval_fate.py load -- -|", false, "<file>"},
+        {"naval_fate.py load a -|", true, "<file>"},
+        {"naval_fate.py --d|", true, "--drifting --dest"},
+        {"naval_fate.py --help |", false, ""},
+        {"naval_fate.py unknown |", false, ""},
+    }
+    for _, table := range tables {
+        // | is the cursor, at the end of the word to complete
+        words := strings.Split(strings.TrimSuffix(table.line, "|"), " ")
+        candidates := m.Complete(words, len(words) - 1, table.options_first)
+        if strings.Join(candidates, " ") != table.expect {
+            t.Errorf("Complete for '%s'\ngot: %q\nwant: '%s'", table.line, candidates, table.expect)
+        }
+    }
+
+    if candidates := m.Complete([]string{"naval_fate.py", "mine", "set"}, 1, false); fmt.Sprint(candidates) != "[mine]" {
+        t.Errorf("Complete in the middle of the line, got: %q, want: [mine]", candidates)
+    }
+    if candidates := m.Complete([]string{"naval_fate.py"}, 5, false); fmt.Sprint(candidates) != "[ship mine load]" {
+        t.Errorf("Complete after the last word, got: %q, want: [ship mine load]", candidates)
+    }
+}
@@ -0,0 +1,335 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_doc.go: markdown and html documentation generated from the help
+// message, see: --generate-doc
+//
+package main
+
+import (
+    "fmt"
+    "html"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// An option row of the documentation table.
+type Doc_option struct {
+    Anchor string
+    Short string
+    Long string
+    Arg string
+    Default string
+    Description string
+}
+
+// The documentation of a program: the text before the Usage: section, the
+// usage lines, its commands, arguments and options. File is the name of the
+// document in batch mode, without its extension.
+type Doc_page struct {
+    Prog string
+    File string
+    Version string
+    Description [][]string
+    Synopsis []string
+    Commands []string
+    Arguments []string
+    Options []Doc_option
+}
+
+// Build the documentation of the help message doc, the first line of version
+// is displayed under the title.
+func New_doc_page(doc, version string) (*Doc_page, error) {
+    usage, err := Parse_usage(doc)
+    if err != nil {
+        return nil, err
+    }
+    page := &Doc_page{Prog: usage.Prog, File: usage.Prog}
+    page.Version, _, _ = partition(strings.TrimSpace(version), "\n")
+
+    for _, s := range Man_sections(doc) {
+        if s.Title != "" {
+            break
+        }
+        for _, paragraph := range man_paragraphs(s.Lines) {
+            page.Description = append(page.Description, man_dedent(paragraph))
+        }
+    }
+
+    _, _, section := partition(usage.Section, ":")
+    for _, line := range strings.Split(section, "\n") {
+        if line = strings.TrimSpace(line); line != "" {
+            page.Synopsis = append(page.Synopsis, line)
+        }
+    }
+
+    options := usage.Options
+    for _, leaf := range usage.Leaves() {
+        switch leaf.Type {
+        case Pattern_command:
+            page.Commands = append(page.Commands, leaf.Name)
+        case Pattern_argument:
+            page.Arguments = append(page.Arguments, leaf.Name)
+        case Pattern_option:
+            if find_pattern(options, leaf) == nil {
+                options = append(options, leaf)
+            }
+        }
+    }
+    for _, o := range options {
+        page.Options = append(page.Options, New_doc_option(o))
+    }
+    return page, nil
+}
+
+// Build the documentation of the script at path, from its comments: the
+// description preceding the usage, the usage and the version, see:
+// Parse_script_description(). File is the name of the script.
+func Read_doc_script(path string, marker string, version_marker string) (*Doc_page, error) {
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("--from-script: %v", err)
+    }
+    usage, version := Parse_script(string(content), marker, version_marker)
+    if usage == "" {
+        return nil, Script_usage_error(path, marker)
+    }
+    if description := Parse_script_description(string(content), marker); description != "" {
+        usage = description + "\n\n" + usage
+    }
+    page, err := New_doc_page(usage, version)
+    if err != nil {
+        return nil, fmt.Errorf("--generate-doc: %s: %v", path, err)
+    }
+    page.File = filepath.Base(path)
+    return page, nil
+}
+
+// The table row of the option o: its description is on a single line, without
+// the [default: ...] part which has its own column.
+func New_doc_option(o *Pattern) Doc_option {
+    anchor := strings.TrimLeft(o.Long, "-")
+    if anchor == "" {
+        anchor = strings.TrimLeft(o.Short, "-")
+    }
+    row := Doc_option{
+        Anchor: "option-" + anchor,
+        Short: o.Short,
+        Long: o.Long,
+        Arg: o.Arg,
+    }
+    if value := Default_value(o.Description); value != nil {
+        row.Default = *value
+    }
+    description := regexp.MustCompile(`(?i)\s*\[default: .*\]`).ReplaceAllString(o.Description, "")
+    row.Description = strings.Join(strings.Fields(description), " ")
+    return row
+}
+
+// The first paragraph of the description on a single line, used in the index.
+func (p *Doc_page) Summary() string {
+    if len(p.Description) == 0 {
+        return ""
+    }
+    return strings.Join(strings.Fields(strings.Join(p.Description[0], " ")), " ")
+}
+
+// Extension of the files written for format.
+func Doc_extension(format string) string {
+    if format == "html" {
+        return ".html"
+    }
+    return ".md"
+}
+
+// Render page in format: markdown or html.
+func Doc_render(format string, page *Doc_page) string {
+    if format == "html" {
+        return Html_doc(page)
+    }
+    return Markdown_doc(page)
+}
+
+// Render the index of pages in format, linking to their files.
+func Doc_index(format string, pages []*Doc_page) string {
+    if format == "html" {
+        return Html_index(pages)
+    }
+    return Markdown_index(pages)
+}
+
+// Write a document per page and the index in directory dir, created if
+// needed. Returns the written files.
+func Write_doc(format string, pages []*Doc_page, dir string) ([]string, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    ext := Doc_extension(format)
+    files := map[string]bool{"index": true}
+    written := []string{}
+    for _, page := range pages {
+        if files[page.File] {
+            return nil, fmt.Errorf("duplicate document name: %s%s", page.File, ext)
+        }
+        files[page.File] = true
+    }
+    for _, page := range pages {
+        path := filepath.Join(dir, page.File+ext)
+        if err := ioutil.WriteFile(path, []byte(Doc_render(format, page)), 0644); err != nil {
+            return nil, err
+        }
+        written = append(written, path)
+    }
+    path := filepath.Join(dir, "index"+ext)
+    if err := ioutil.WriteFile(path, []byte(Doc_index(format, pages)), 0644); err != nil {
+        return nil, err
+    }
+    return append(written, path), nil
+}
+
+// Text in a markdown table cell: pipes would split the cell.
+func markdown_cell(s string) string {
+    return strings.Replace(s, "|", `\|`, -1)
+}
+
+// Inline code in markdown, with enough backticks around s.
+func markdown_code(s string) string {
+    if s == "" {
+        return ""
+    }
+    fence := "`"
+    for strings.Contains(s, fence) {
+        fence += "`"
+    }
+    if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+        return fence + " " + s + " " + fence
+    }
+    return fence + s + fence
+}
+
+// A markdown document: title, description, synopsis code block, commands,
+// arguments and the options table with an anchor per option.
+func Markdown_doc(p *Doc_page) string {
+    var result string
+    result += "# " + p.Prog + "\n\n"
+    if p.Version != "" {
+        result += "_" + p.Version + "_\n\n"
+    }
+    for _, paragraph := range p.Description {
+        result += strings.Join(paragraph, "\n") + "\n\n"
+    }
+
+    result += "## Synopsis\n\n```\n" + strings.Join(p.Synopsis, "\n") + "\n```\n"
+
+    if len(p.Commands) > 0 {
+        result += "\n## Commands\n\n"
+        for _, c := range p.Commands {
+            result += "- " + markdown_code(c) + "\n"
+        }
+    }
+    if len(p.Arguments) > 0 {
+        result += "\n## Arguments\n\n"
+        for _, a := range p.Arguments {
+            result += "- " + markdown_code(a) + "\n"
+        }
+    }
+    if len(p.Options) > 0 {
+        result += "\n## Options\n\n"
+        result += "| Short | Long | Argument | Default | Description |\n"
+        result += "|-------|------|----------|---------|-------------|\n"
+        for _, o := range p.Options {
+            result += fmt.Sprintf("| <a id=\"%s\"></a>%s | %s | %s | %s | %s |\n", o.Anchor,
+                markdown_cell(markdown_code(o.Short)),
+                markdown_cell(markdown_code(o.Long)),
+                markdown_cell(markdown_code(o.Arg)),
+                markdown_cell(markdown_code(o.Default)),
+                markdown_cell(o.Description))
+        }
+    }
+    return result
+}
+
+// The markdown index: a link to each document with its summary.
+func Markdown_index(pages []*Doc_page) string {
+    result := "# Index\n\n"
+    for _, p := range pages {
+        result += fmt.Sprintf("- [%s](%s)", p.Prog, p.File+".md")
+        if summary := p.Summary(); summary != "" {
+            result += ": " + summary
+        }
+        result += "\n"
+    }
+    return result
+}
+
+// Head of a standalone html page.
+func html_head(title string) string {
+    return "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n" +
+        "<title>" + html.EscapeString(title) + "</title>\n</head>\n<body>\n"
+}
+
+const html_tail = "</body>\n</html>\n"
+
+// An html document, same content as Markdown_doc().
+func Html_doc(p *Doc_page) string {
+    result := html_head(p.Prog)
+    result += "<h1>" + html.EscapeString(p.Prog) + "</h1>\n"
+    if p.Version != "" {
+        result += "<p><em>" + html.EscapeString(p.Version) + "</em></p>\n"
+    }
+    for _, paragraph := range p.Description {
+        result += "<p>" + html.EscapeString(strings.Join(paragraph, "\n")) + "</p>\n"
+    }
+
+    result += "<h2 id=\"synopsis\">Synopsis</h2>\n<pre><code>"
+    result += html.EscapeString(strings.Join(p.Synopsis, "\n")) + "</code></pre>\n"
+
+    if len(p.Commands) > 0 {
+        result += "<h2 id=\"commands\">Commands</h2>\n<ul>\n"
+        for _, c := range p.Commands {
+            result += "<li><code>" + html.EscapeString(c) + "</code></li>\n"
+        }
+        result += "</ul>\n"
+    }
+    if len(p.Arguments) > 0 {
+        result += "<h2 id=\"arguments\">Arguments</h2>\n<ul>\n"
+        for _, a := range p.Arguments {
+            result += "<li><code>" + html.EscapeString(a) + "</code></li>\n"
+        }
+        result += "</ul>\n"
+    }
+    if len(p.Options) > 0 {
+        result += "<h2 id=\"options\">Options</h2>\n<table>\n"
+        result += "<tr><th>Short</th><th>Long</th><th>Argument</th><th>Default</th><th>Description</th></tr>\n"
+        for _, o := range p.Options {
+            result += fmt.Sprintf("<tr id=\"%s\"><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+                html.EscapeString(o.Anchor), html_code(o.Short), html_code(o.Long),
+                html_code(o.Arg), html_code(o.Default), html.EscapeString(o.Description))
+        }
+        result += "</table>\n"
+    }
+    return result + html_tail
+}
+
+func html_code(s string) string {
+    if s == "" {
+        return ""
+    }
+    return "<code>" + html.EscapeString(s) + "</code>"
+}
+
+// The html index, same content as Markdown_index().
+func Html_index(pages []*Doc_page) string {
+    result := html_head("Index") + "<h1>Index</h1>\n<ul>\n"
+    for _, p := range pages {
+        result += fmt.Sprintf("<li><a href=\"%s\">%s</a>", html.EscapeString(p.File+".html"),
+            html.EscapeString(p.Prog))
+        if summary := p.Summary(); summary != "" {
+            result += ": " + html.EscapeString(summary)
+        }
+        result += "</li>\n"
+    }
+    return result + "</ul>\n" + html_tail
+}
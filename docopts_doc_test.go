@@ -0,0 +1,179 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_doc.go
+//
+package main
+
+import (
+    "testing"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+var Doc_usage = `Naval Fate.
+
+Usage:
+  naval_fate ship <name> move <x> <y> [--speed=<kn>]
+  naval_fate mine (set|remove) <x> <y> [--moored|--drifting] [-q]
+
+Options:
+  -h --help     Show this screen.
+  --speed=<kn>  Speed in knots
+                [default: 10].
+  --moored      Moored | anchored.
+`
+
+func TestNew_doc_page(t *testing.T) {
+    page, err := New_doc_page(Doc_usage, "Naval Fate 2.0\nCopyright")
+    if err != nil {
+        t.Fatalf("New_doc_page: %v", err)
+    }
+    if page.Prog != "naval_fate" || page.File != "naval_fate" || page.Version != "Naval Fate 2.0" {
+        t.Errorf("New_doc_page, got: %q %q %q", page.Prog, page.File, page.Version)
+    }
+    if page.Summary() != "Naval Fate." {
+        t.Errorf("Summary, got: %q", page.Summary())
+    }
+    if len(page.Synopsis) != 2 || page.Synopsis[1] != "naval_fate mine (set|remove) <x> <y> [--moored|--drifting] [-q]" {
+        t.Errorf("Synopsis, got: %q", page.Synopsis)
+    }
+    if strings.Join(page.Commands, " ") != "ship move mine set remove" {
+        t.Errorf("Commands, got: %q", page.Commands)
+    }
+    if strings.Join(page.Arguments, " ") != "<name> <x> <y>" {
+        t.Errorf("Arguments, got: %q", page.Arguments)
+    }
+
+    expect := []Doc_option{
+        {"option-help", "-h", "--help", "", "", "Show this screen."},
+        {"option-speed", "", "--speed", "<kn>", "10", "Speed in knots."},
+        {"option-moored", "", "--moored", "", "", "Moored | anchored."},
+        {"option-drifting", "", "--drifting", "", "", ""},
+        {"option-q", "-q", "", "", "", ""},
+    }
+    if len(page.Options) != len(expect) {
+        t.Fatalf("Options, got: %v, want: %v", page.Options, expect)
+    }
+    for i, o := range expect {
+        if page.Options[i] != o {
+            t.Errorf("Options[%d], got: %v, want: %v", i, page.Options[i], o)
+        }
+    }
+
+    if _, err := New_doc_page("no usage", ""); err == nil {
+        t.Errorf("New_doc_page without usage, error expected")
+    }
+}
+
+func TestMarkdown_doc(t *testing.T) {
+    page, _ := New_doc_page(Doc_usage, "Naval Fate 2.0")
+    expect := "# naval_fate\n\n_Naval Fate 2.0_\n\nNaval Fate.\n\n" +
+        "## Synopsis\n\n```\n" +
+        "naval_fate ship <name> move <x> <y> [--speed=<kn>]\n" +
+        "naval_fate mine (set|remove) <x> <y> [--moored|--drifting] [-q]\n```\n" +
+        "\n## Commands\n\n- `ship`\n- `move`\n- `mine`\n- `set`\n- `remove`\n" +
+        "\n## Arguments\n\n- `<name>`\n- `<x>`\n- `<y>`\n" +
+        "\n## Options\n\n" +
+        "| Short | Long | Argument | Default | Description |\n" +
+        "|-------|------|----------|---------|-------------|\n" +
+        "| <a id=\"option-help\"></a>`-h` | `--help` |  |  | Show this screen. |\n" +
+        "| <a id=\"option-speed\"></a> | `--speed` | `<kn>` | `10` | Speed in knots. |\n" +
+        "| <a id=\"option-moored\"></a> | `--moored` |  |  | Moored \\| anchored. |\n" +
+        "| <a id=\"option-drifting\"></a> | `--drifting` |  |  |  |\n" +
+        "| <a id=\"option-q\"></a>`-q` |  |  |  |  |\n"
+    if got := Markdown_doc(page); got != expect {
+        t.Errorf("Markdown_doc, got:\n%s\nwant:\n%s", got, expect)
+    }
+
+    if got := markdown_code("a`b"); got != "``a`b``" {
+        t.Errorf("markdown_code with a backtick, got: %s", got)
+    }
+}
+
+func TestHtml_doc(t *testing.T) {
+    page, _ := New_doc_page(Doc_usage, "")
+    got := Html_doc(page)
+    for _, expect := range []string{
+        "<!DOCTYPE html>\n",
+        "<title>naval_fate</title>\n",
+        "<h1>naval_fate</h1>\n<p>Naval Fate.</p>\n",
+        "<pre><code>naval_fate ship &lt;name&gt; move &lt;x&gt; &lt;y&gt; [--speed=&lt;kn&gt;]\n",
+        "<li><code>&lt;name&gt;</code></li>\n",
+        "<tr id=\"option-speed\"><td></td><td><code>--speed</code></td><td><code>&lt;kn&gt;</code></td>" +
+            "<td><code>10</code></td><td>Speed in knots.</td></tr>\n",
+        "</table>\n</body>\n</html>\n",
+    } {
+        if !strings.Contains(got, expect) {
+            t.Errorf("Html_doc, %q not found in:\n%s", expect, got)
+        }
+    }
+    if strings.Contains(got, "<em>") {
+        t.Errorf("Html_doc without version, got:\n%s", got)
+    }
+}
+
+func TestWrite_doc(t *testing.T) {
+    dir, err := ioutil.TempDir("", "docopts_doc")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    naval, _ := New_doc_page(Doc_usage, "")
+    naval.File = "naval_fate.sh"
+    other, _ := New_doc_page("Usage: other <file>", "")
+    dir = filepath.Join(dir, "doc")
+    files, err := Write_doc("markdown", []*Doc_page{naval, other}, dir)
+    if err != nil {
+        t.Fatalf("Write_doc: %v", err)
+    }
+    expect := []string{"naval_fate.sh.md", "other.md", "index.md"}
+    if len(files) != len(expect) {
+        t.Fatalf("Write_doc, got: %v", files)
+    }
+    for i, name := range expect {
+        if files[i] != filepath.Join(dir, name) {
+            t.Errorf("Write_doc files[%d], got: %s, want: %s", i, files[i], name)
+        }
+    }
+    index, _ := ioutil.ReadFile(filepath.Join(dir, "index.md"))
+    expect_index := "# Index\n\n- [naval_fate](naval_fate.sh.md): Naval Fate.\n- [other](other.md)\n"
+    if string(index) != expect_index {
+        t.Errorf("Write_doc index, got:\n%s\nwant:\n%s", index, expect_index)
+    }
+
+    if _, err := Write_doc("html", []*Doc_page{other, other}, dir); err == nil ||
+        err.Error() != "duplicate document name: other.html" {
+        t.Errorf("Write_doc with a duplicate, got: %v", err)
+    }
+}
+
+func TestRead_doc_script(t *testing.T) {
+    dir, err := ioutil.TempDir("", "docopts_doc")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    path := filepath.Join(dir, "naval_fate.sh")
+    script := "#!/bin/bash\n# Naval Fate.\n#\n# Usage: naval_fate.sh ship <name>\n# ----\n# Naval Fate 2.0\n"
+    ioutil.WriteFile(path, []byte(script), 0644)
+    page, err := Read_doc_script(path, "#", "")
+    if err != nil {
+        t.Fatalf("Read_doc_script: %v", err)
+    }
+    if page.File != "naval_fate.sh" || page.Summary() != "Naval Fate." || page.Version != "Naval Fate 2.0" {
+        t.Errorf("Read_doc_script, got: %q %q %q", page.File, page.Summary(), page.Version)
+    }
+
+    ioutil.WriteFile(path, []byte("# Usage: naval_fate.sh (ship\n"), 0644)
+    if _, err := Read_doc_script(path, "#", ""); err == nil ||
+        !strings.HasPrefix(err.Error(), "--generate-doc: "+path+": ") {
+        t.Errorf("Read_doc_script with a bad usage, got: %v", err)
+    }
+    if _, err := Read_doc_script(filepath.Join(dir, "none"), "#", ""); err == nil {
+        t.Errorf("Read_doc_script of a missing file, error expected")
+    }
+}
@@ -0,0 +1,140 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_exec.go: pass the parsed arguments as environment variables, see:
+// docopts exec and --export
+//
+package main
+
+import (
+    "fmt"
+    "github.com/docopt/docopt-go"
+    "os"
+    "os/exec"
+    "strings"
+    "syscall"
+)
+
+// separator of the program arguments and the command with docopts exec
+const Exec_separator = "--"
+
+// Split argv of docopts exec at the first Exec_separator: the arguments parsed
+// by the usage, and the command to execute with its own arguments.
+func Split_exec_argv(argv []string) ([]string, []string, error) {
+    for i, a := range argv {
+        if a == Exec_separator {
+            if i == len(argv) - 1 {
+                break
+            }
+            return argv[:i], argv[i+1:], nil
+        }
+    }
+    return nil, nil, fmt.Errorf("exec: no command given, use: : [<argv>...] %s command [args...]", Exec_separator)
+}
+
+// HelpHandler used by docopts exec: nothing is evaled, the help or error
+// message is output directly and docopts exits 0 or Exit_usage.
+func HelpHandler_for_exec(err error, usage string) {
+    if err != nil {
+        fmt.Fprintln(os.Stderr, Error_message(err, usage))
+        os.Exit(Exit_usage)
+    }
+    // --help or --version found and --no-help was not given
+    fmt.Println(usage)
+    os.Exit(0)
+}
+
+// Convert a parsed value to the raw string of an environment variable: no
+// quoting, nil is an empty string.
+func To_env(v interface{}) string {
+    if v == nil {
+        return ""
+    }
+    return fmt.Sprintf("%v", v)
+}
+
+// Environment variables NAME=value for the parsed arguments. Names are mangled
+// as in global mode, prefixed by Docopts.Global_prefix. Repeatable arguments
+// are encoded according to Docopts.Env_arrays:
+// indexed: NAME_COUNT=length and NAME_i=value, 'i' from 0 to length - 1
+// newline: NAME_COUNT=length and NAME holding the values joined by a newline
+func (d *Docopts) Exec_env(args docopt.Opts) ([]string, error) {
+    names, err := d.Posix_names(args)
+    if err != nil {
+        return nil, err
+    }
+
+    env := []string{}
+    for _, key := range d.Ordered_keys(args) {
+        val_arr, ok := args[key].([]string)
+        if !ok {
+            env = append(env, names[key] + "=" + To_env(args[key]))
+            continue
+        }
+        env = append(env, fmt.Sprintf("%s=%d", names[key + ",#"], len(val_arr)))
+        if d.Env_arrays == "newline" {
+            env = append(env, names[key] + "=" + strings.Join(val_arr, "\n"))
+            continue
+        }
+        for index, v := range val_arr {
+            env = append(env, fmt.Sprintf("%s=%s", names[fmt.Sprintf("%s,%d", key, index)], v))
+        }
+    }
+    return env, nil
+}
+
+// Replace docopts by command, with the parsed arguments added to the current
+// environment. Only returns on failure.
+func (d *Docopts) Exec(args docopt.Opts, command []string) error {
+    env, err := d.Exec_env(args)
+    if err != nil {
+        return err
+    }
+
+    // error message is already prefixed by exec:
+    path, err := exec.LookPath(command[0])
+    if err != nil {
+        return err
+    }
+
+    // our variables override the inherited ones
+    set := make(map[string]bool, len(env))
+    for _, e := range env {
+        set[e[:strings.Index(e, "=")]] = true
+    }
+    for _, e := range os.Environ() {
+        if i := strings.Index(e, "="); i == -1 || !set[e[:i]] {
+            env = append(env, e)
+        }
+    }
+
+    return syscall.Exec(path, command, env)
+}
+
+// Prefix of global assignments: local with --function, export with --export,
+// both in bash and zsh with 'local -x'. Not mangled names are never declared.
+func (d *Docopts) Global_declare() string {
+    if ! d.Mangle_key {
+        return ""
+    }
+    switch {
+    case d.Exit_function && d.Export && d.Shell != "posix":
+        return "local -x "
+    case d.Export:
+        return "export "
+    case d.Exit_function:
+        return "local "
+    }
+    return ""
+}
+
+// With --export, a repeatable argument can't be exported as an array: it is
+// also exported with the indexed encoding of docopts exec, NAME_COUNT and NAME_i.
+// names are given by Posix_names().
+func (d *Docopts) Export_array_source(names map[string]string, key string, val_arr []string) string {
+    declare := d.Global_declare()
+    source := fmt.Sprintf("%s%s=%d\n", declare, names[key + ",#"], len(val_arr))
+    for index, v := range val_arr {
+        source += fmt.Sprintf("%s%s=%s\n", declare, names[fmt.Sprintf("%s,%d", key, index)], d.Quote(v))
+    }
+    return source
+}
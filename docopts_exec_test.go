@@ -0,0 +1,111 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_exec.go
+//
+package main
+
+import (
+    "testing"
+    "bytes"
+    "reflect"
+)
+
+func TestSplit_exec_argv(t *testing.T) {
+    argv, command, err := Split_exec_argv([]string{"-v", "a", "--", "env", "--", "x"})
+    if err != nil {
+        t.Fatalf("Split_exec_argv error: %v", err)
+    }
+    if !reflect.DeepEqual(argv, []string{"-v", "a"}) {
+        t.Errorf("Split_exec_argv argv, got: %v, want: [-v a]", argv)
+    }
+    if !reflect.DeepEqual(command, []string{"env", "--", "x"}) {
+        t.Errorf("Split_exec_argv command, got: %v, want: [env -- x]", command)
+    }
+
+    for _, bad := range [][]string{{}, {"a"}, {"a", "--"}} {
+        if _, _, err := Split_exec_argv(bad); err == nil {
+            t.Errorf("Split_exec_argv(%v) should fail", bad)
+        }
+    }
+}
+
+func TestExec_env(t *testing.T) {
+    d := &Docopts{
+        Global_prefix: "P",
+        Mangle_key: true,
+        Env_arrays: "indexed",
+        Key_order: []string{"-v", "--out", "--in", "FILE"},
+    }
+    args := map[string]interface{}{
+        "-v": 2,
+        "--out": "it's\nhere",
+        "--in": nil,
+        "FILE": []string{"a b", "c"},
+    }
+
+    env, err := d.Exec_env(args)
+    if err != nil {
+        t.Fatalf("Exec_env error: %v", err)
+    }
+    expect := []string{"P_v=2", "P_out=it's\nhere", "P_in=", "P_FILE_COUNT=2", "P_FILE_0=a b", "P_FILE_1=c"}
+    if !reflect.DeepEqual(env, expect) {
+        t.Errorf("Exec_env indexed\ngot: %q\nwant: %q", env, expect)
+    }
+
+    d.Env_arrays = "newline"
+    env, _ = d.Exec_env(args)
+    expect = []string{"P_v=2", "P_out=it's\nhere", "P_in=", "P_FILE_COUNT=2", "P_FILE=a b\nc"}
+    if !reflect.DeepEqual(env, expect) {
+        t.Errorf("Exec_env newline\ngot: %q\nwant: %q", env, expect)
+    }
+
+    d.Global_prefix = ""
+    d.Reserved = "error"
+    if _, err := d.Exec_env(map[string]interface{}{"<path>": "x"}); err == nil {
+        t.Errorf("Exec_env should refuse to overwrite PATH")
+    }
+
+    // keys are not variable names, no entry without a name
+    d.Mangle_key = false
+    if env, err := d.Exec_env(args); err == nil {
+        t.Errorf("Exec_env should refuse --no-mangle, got: %q", env)
+    }
+}
+
+func TestGlobal_declare(t *testing.T) {
+    tables := []struct {
+        d Docopts
+        expect string
+    }{
+        {Docopts{Mangle_key: true}, ""},
+        {Docopts{Mangle_key: true, Exit_function: true}, "local "},
+        {Docopts{Mangle_key: true, Export: true}, "export "},
+        {Docopts{Mangle_key: true, Export: true, Exit_function: true}, "local -x "},
+        {Docopts{Mangle_key: true, Export: true, Exit_function: true, Shell: "posix"}, "export "},
+        {Docopts{Mangle_key: false, Export: true}, ""},
+    }
+    for _, table := range tables {
+        if res := table.d.Global_declare(); res != table.expect {
+            t.Errorf("Global_declare(%+v), got: '%v', want: '%v'", table.d, res, table.expect)
+        }
+    }
+}
+
+func TestPrint_bash_global_export(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d := &Docopts{
+        Mangle_key: true,
+        Export: true,
+        Key_order: []string{"--out", "FILE"},
+    }
+    d.Print_bash_global(map[string]interface{}{"--out": "x", "FILE": []string{"a b"}})
+    res := out.(*bytes.Buffer).String()
+    expect := "export out='x'\nFILE=('a b')\nexport FILE_COUNT=1\nexport FILE_0='a b'\n"
+    if res != expect {
+        t.Errorf("Print_bash_global with Export\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
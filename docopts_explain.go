@@ -0,0 +1,338 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_explain.go: tell which usage line matched argv, or the closest one
+// and why it failed, see: --explain
+//
+package main
+
+import (
+    "fmt"
+    "os"
+    "strings"
+)
+
+// An element of argv: an option, with its argument, or a positional argument.
+type explain_word struct {
+    // name of the matching option, like --output for -o, empty for a
+    // positional argument
+    Option string
+    // the option as given, like -o or --out=x, or the positional argument
+    Text string
+    // false for an option not defined by the usage
+    Known bool
+}
+
+// A leaf of a usage line which could not be matched, Got is the positional
+// argument found instead of a command.
+type explain_missing struct {
+    Pattern *Pattern
+    Got *explain_word
+}
+
+// A reason why argv doesn't match a usage line.
+type Explain_issue struct {
+    // missing, unexpected, unknown_option, extra_argument or invalid
+    Reason string `json:"reason"`
+    // the usage element or the word of argv
+    Element string `json:"element,omitempty"`
+    Message string `json:"message"`
+}
+
+// The result of --explain. Line is the 1-based index of the usage line which
+// matched, or of the closest one, 0 if argv can't be parsed.
+type Explanation struct {
+    Matched bool `json:"matched"`
+    Line int `json:"line"`
+    Usage string `json:"usage"`
+    Issues []Explain_issue `json:"issues"`
+}
+
+// The options to parse argv: the described ones and those only found in the
+// patterns.
+func explain_options(u *Usage_model) []*Pattern {
+    options := append([]*Pattern{}, u.Options...)
+    for _, o := range u.Pattern.Flat(Pattern_option) {
+        if find_pattern(options, o) == nil {
+            options = append(options, o)
+        }
+    }
+    return options
+}
+
+// Split argv into words, as docopt does: options are resolved by their
+// description, long ones by a unique prefix, and take their argument. After
+// '--', or the first positional argument if options_first, all are positional.
+func Explain_argv(u *Usage_model, argv []string, options_first bool) ([]explain_word, error) {
+    options := explain_options(u)
+    words := []explain_word{}
+    for i := 0; i < len(argv); i++ {
+        arg := argv[i]
+        switch {
+        case arg == "--":
+            for _, a := range argv[i:] {
+                words = append(words, explain_word{Text: a})
+            }
+            return words, nil
+        case strings.HasPrefix(arg, "--"):
+            long, eq, _ := partition(arg, "=")
+            similar := []*Pattern{}
+            for _, o := range options {
+                if o.Long == long {
+                    similar = append(similar, o)
+                }
+            }
+            if len(similar) == 0 {
+                for _, o := range options {
+                    if o.Long != "" && strings.HasPrefix(o.Long, long) {
+                        similar = append(similar, o)
+                    }
+                }
+            }
+            if len(similar) > 1 {
+                names := []string{}
+                for _, o := range similar {
+                    names = append(names, o.Long)
+                }
+                return nil, fmt.Errorf("%s is not a unique prefix: %s?", long, strings.Join(names, ", "))
+            }
+            if len(similar) == 0 {
+                words = append(words, explain_word{Option: long, Text: arg})
+                continue
+            }
+            o := similar[0]
+            if o.Argcount == 0 && eq == "=" {
+                return nil, fmt.Errorf("%s must not have an argument", o.Long)
+            }
+            if o.Argcount > 0 && eq == "" {
+                if i+1 == len(argv) || argv[i+1] == "--" {
+                    return nil, fmt.Errorf("%s requires argument", o.Long)
+                }
+                i++
+            }
+            words = append(words, explain_word{o.Name, arg, true})
+        case strings.HasPrefix(arg, "-") && arg != "-":
+            left := arg[1:]
+            for left != "" {
+                short := "-" + left[0:1]
+                left = left[1:]
+                similar := []*Pattern{}
+                for _, o := range options {
+                    if o.Short == short {
+                        similar = append(similar, o)
+                    }
+                }
+                if len(similar) > 1 {
+                    return nil, fmt.Errorf("%s is specified ambiguously %d times", short, len(similar))
+                }
+                if len(similar) == 0 {
+                    words = append(words, explain_word{Option: short, Text: short})
+                    continue
+                }
+                o := similar[0]
+                words = append(words, explain_word{o.Name, short, true})
+                if o.Argcount > 0 {
+                    if left == "" {
+                        if i+1 == len(argv) || argv[i+1] == "--" {
+                            return nil, fmt.Errorf("%s requires argument", short)
+                        }
+                        i++
+                    }
+                    left = ""
+                }
+            }
+        case options_first:
+            for _, a := range argv[i:] {
+                words = append(words, explain_word{Text: a})
+            }
+            return words, nil
+        default:
+            words = append(words, explain_word{Text: arg})
+        }
+    }
+    return words, nil
+}
+
+func explain_remove(words []explain_word, i int) []explain_word {
+    return append(append([]explain_word{}, words[:i]...), words[i+1:]...)
+}
+
+// The outcome of matching a pattern: the words of argv left, the leaves
+// missing and the ones matched.
+type explain_result struct {
+    Left []explain_word
+    Missing []explain_missing
+    Matched []*Pattern
+}
+
+// Match the pattern p against the words left, as docopt does, but a required
+// leaf which is not found is added to Missing instead of failing: p matches if
+// nothing is missing.
+func explain_match(p *Pattern, left []explain_word) explain_result {
+    r := explain_result{Left: left, Missing: []explain_missing{}, Matched: []*Pattern{}}
+    switch p.Type {
+    case Pattern_argument, Pattern_command:
+        for i, w := range left {
+            if w.Option != "" {
+                continue
+            }
+            if p.Type == Pattern_argument || w.Text == p.Name {
+                r.Left, r.Matched = explain_remove(left, i), []*Pattern{p}
+                return r
+            }
+            // a command is only the first positional argument
+            got := w
+            r.Missing = []explain_missing{{p, &got}}
+            return r
+        }
+        r.Missing = []explain_missing{{p, nil}}
+    case Pattern_option:
+        for i, w := range left {
+            if w.Option == p.Name {
+                r.Left, r.Matched = explain_remove(left, i), []*Pattern{p}
+                return r
+            }
+        }
+        r.Missing = []explain_missing{{p, nil}}
+    case Pattern_required:
+        for _, child := range p.Children {
+            c := explain_match(child, r.Left)
+            r.Left = c.Left
+            r.Missing = append(r.Missing, c.Missing...)
+            r.Matched = append(r.Matched, c.Matched...)
+        }
+    case Pattern_optional, Pattern_options_shortcut:
+        for _, child := range p.Children {
+            if c := explain_match(child, r.Left); len(c.Missing) == 0 {
+                r.Left = c.Left
+                r.Matched = append(r.Matched, c.Matched...)
+            }
+        }
+    case Pattern_one_or_more:
+        r = explain_match(p.Children[0], left)
+        for len(r.Missing) == 0 {
+            c := explain_match(p.Children[0], r.Left)
+            if len(c.Missing) > 0 || len(c.Left) == len(r.Left) {
+                break
+            }
+            r.Left = c.Left
+            r.Matched = append(r.Matched, c.Matched...)
+        }
+    case Pattern_either:
+        // docopt takes the first alternative leaving the fewest words
+        for i, child := range p.Children {
+            c := explain_match(child, left)
+            if i == 0 || len(c.Missing) < len(r.Missing) ||
+                len(c.Missing) == len(r.Missing) && len(c.Left) < len(r.Left) {
+                r = c
+            }
+        }
+    }
+    return r
+}
+
+// The issues of a usage line, missing elements first, then the words of argv
+// left over.
+func explain_issues(r explain_result, options []*Pattern) []Explain_issue {
+    issues := []Explain_issue{}
+    for _, m := range r.Missing {
+        issue := Explain_issue{Reason: "missing", Element: m.Pattern.Name}
+        switch {
+        case m.Got != nil:
+            issue.Message = fmt.Sprintf("expected command %s, got '%s'", m.Pattern.Name, m.Got.Text)
+        case m.Pattern.Type == Pattern_command:
+            issue.Message = fmt.Sprintf("missing command %s", m.Pattern.Name)
+        case m.Pattern.Type == Pattern_option:
+            issue.Message = fmt.Sprintf("missing option %s", m.Pattern.Name)
+        default:
+            issue.Message = fmt.Sprintf("missing %s", m.Pattern.Name)
+        }
+        issues = append(issues, issue)
+    }
+
+    names := []string{}
+    for _, o := range options {
+        names = append(names, Completion_option_names(o)...)
+    }
+    for _, w := range r.Left {
+        switch {
+        case w.Option == "":
+            issues = append(issues, Explain_issue{"extra_argument", w.Text,
+                fmt.Sprintf("extra positional argument '%s'", w.Text)})
+        case !w.Known:
+            message := fmt.Sprintf("unknown option %s", w.Option)
+            if closest := Check_closest(w.Option, names); closest != "" {
+                message += fmt.Sprintf(", did you mean %s?", closest)
+            }
+            issues = append(issues, Explain_issue{"unknown_option", w.Text, message})
+        default:
+            issues = append(issues, Explain_issue{"unexpected", w.Text,
+                fmt.Sprintf("unexpected option %s", w.Text)})
+        }
+    }
+    return issues
+}
+
+// Explain how argv matches the usage u: the first usage line matched, or the
+// closest one, with the fewest issues then the most commands and elements
+// matched, the first one on a tie.
+func Explain(u *Usage_model, argv []string, options_first bool) *Explanation {
+    words, err := Explain_argv(u, argv, options_first)
+    if err != nil {
+        return &Explanation{Issues: []Explain_issue{{Reason: "invalid", Message: err.Error()}}}
+    }
+    options := explain_options(u)
+    lines := u.Lines()
+    best := &Explanation{Issues: []Explain_issue{}}
+    best_score := [2]int{}
+    for i, pattern := range u.Patterns() {
+        r := explain_match(pattern, words)
+        e := &Explanation{Line: i + 1, Issues: explain_issues(r, options)}
+        if i < len(lines) {
+            e.Usage = lines[i]
+        }
+        if len(e.Issues) == 0 {
+            e.Matched = true
+            return e
+        }
+        // a command matched is a stronger hint than an argument taking a word
+        matched := unique_patterns(r.Matched)
+        score := [2]int{0, len(matched)}
+        for _, m := range matched {
+            if m.Type == Pattern_command {
+                score[0]++
+            }
+        }
+        better := score[0] > best_score[0] || score[0] == best_score[0] && score[1] > best_score[1]
+        if i == 0 || len(e.Issues) < len(best.Issues) || len(e.Issues) == len(best.Issues) && better {
+            best, best_score = e, score
+        }
+    }
+    return best
+}
+
+// The explanation as text, a line for the usage line then one per issue.
+func (e *Explanation) String() string {
+    lines := []string{}
+    switch {
+    case e.Matched:
+        lines = append(lines, fmt.Sprintf("matched usage line %d: %s", e.Line, e.Usage))
+    case e.Line > 0:
+        lines = append(lines, fmt.Sprintf("no usage line matched, closest is line %d: %s", e.Line, e.Usage))
+    }
+    for _, issue := range e.Issues {
+        lines = append(lines, issue.Message)
+    }
+    return strings.Join(lines, "\n")
+}
+
+// Output the explanation on stderr, stdout is evaluated by the shell: as a
+// JSON object with --json, as text lines otherwise.
+func Print_explanation(e *Explanation, json_output bool) {
+    if json_output {
+        fmt.Fprintln(os.Stderr, To_json(e))
+        return
+    }
+    for _, line := range strings.Split(e.String(), "\n") {
+        fmt.Fprintf(os.Stderr, "docopts:explain: %s\n", line)
+    }
+}
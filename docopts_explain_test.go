@@ -0,0 +1,96 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_explain.go
+//
+package main
+
+import (
+    "testing"
+    "strings"
+)
+
+var Explain_usage = `Usage:
+  ship new <name>...
+  ship <name> move <x> <y> [--speed=<kn>]
+  ship mine (set|remove) <x> <y> [--moored|--drifting]
+  ship -h | --help
+
+Options:
+  -s KN, --speed=<kn>  Speed in knots [default: 10].
+  --moored             Moored mine.
+  --drifting           Drifting mine.
+  -h --help            Show this screen.`
+
+func TestExplain_argv(t *testing.T) {
+    u, err := Parse_usage(Explain_usage)
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    words, err := Explain_argv(u, strings.Fields("a -s5 --mo --spe 3 --x -- -h"), false)
+    if err != nil {
+        t.Fatalf("Explain_argv error: %v", err)
+    }
+    expect := []explain_word{{"", "a", false}, {"--speed", "-s", true}, {"--moored", "--mo", true},
+        {"--speed", "--spe", true}, {"--x", "--x", false}, {"", "--", false}, {"", "-h", false}}
+    if len(words) != len(expect) {
+        t.Fatalf("Explain_argv, got: %v, want: %v", words, expect)
+    }
+    for i := range expect {
+        if words[i] != expect[i] {
+            t.Errorf("Explain_argv word %d, got: %v, want: %v", i, words[i], expect[i])
+        }
+    }
+
+    words, _ = Explain_argv(u, []string{"new", "-h"}, true)
+    if len(words) != 2 || words[1].Option != "" {
+        t.Errorf("Explain_argv options first, got: %v", words)
+    }
+
+    tables := map[string]string{
+        "--speed": "--speed requires argument",
+        "-s": "-s requires argument",
+        "--moored=1": "--moored must not have an argument",
+        "--d": "",
+    }
+    for argv, expect := range tables {
+        _, err := Explain_argv(u, strings.Fields(argv), false)
+        if expect == "" && err != nil || expect != "" && (err == nil || err.Error() != expect) {
+            t.Errorf("Explain_argv(%q), got error: %v, want: %q", argv, err, expect)
+        }
+    }
+}
+
+func TestExplain(t *testing.T) {
+    u, err := Parse_usage(Explain_usage)
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    tables := map[string]string{
+        "new a b": "matched usage line 1: ship new <name>...",
+        "a move 1 2 --speed=3": "matched usage line 2: ship <name> move <x> <y> [--speed=<kn>]",
+        "a move 1": "no usage line matched, closest is line 2: ship <name> move <x> <y> [--speed=<kn>]\n" +
+            "missing <y>",
+        "mine set 1 2 --moored --drifting": "no usage line matched, closest is line 3: ship mine (set|remove) <x> <y> [--moored|--drifting]\n" +
+            "unexpected option --drifting",
+        "mine 1 2": "no usage line matched, closest is line 3: ship mine (set|remove) <x> <y> [--moored|--drifting]\n" +
+            "expected command set, got '1'",
+        "a move 1 2 3 --drifing": "no usage line matched, closest is line 2: ship <name> move <x> <y> [--speed=<kn>]\n" +
+            "extra positional argument '3'\n" +
+            "unknown option --drifing, did you mean --drifting?",
+        "a move 1 2 --speed": "--speed requires argument",
+    }
+    for argv, expect := range tables {
+        if got := Explain(u, strings.Fields(argv), false).String(); got != expect {
+            t.Errorf("Explain(%q), got:\n%s\nwant:\n%s", argv, got, expect)
+        }
+    }
+
+    e := Explain(u, []string{}, false)
+    if e.Matched || e.Line != 4 || len(e.Issues) != 1 || e.Issues[0].Reason != "missing" || e.Issues[0].Element != "--help" {
+        t.Errorf("Explain without argument, got: %s", To_json(e))
+    }
+    expect := `{"matched":true,"line":4,"usage":"ship -h | --help","issues":[]}`
+    if got := To_json(Explain(u, []string{"-h"}, false)); got != expect {
+        t.Errorf("Explain -h, got: %s, want: %s", got, expect)
+    }
+}
@@ -0,0 +1,96 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_fish.go: fish output backend, see: --shell=fish
+//
+// The output is intended for fish's eval, which shares the caller's scope:
+//   eval (docopts --shell=fish -h $usage : $argv | string collect)
+// so set -l declares variables local to the calling function, and exit or
+// return stop the calling script or function with the right $status.
+//
+package main
+
+import (
+    "fmt"
+    "github.com/docopt/docopt-go"
+    "reflect"
+    "strings"
+)
+
+// Performs output for fish variables, names are mangled the same way as
+// Print_bash_global(). Variables are global, or local to the calling function
+// if Docopts.Exit_function is true. Repeatable arguments are fish lists.
+// With Docopts.Export, lists are also exported as with docopts exec.
+func (d *Docopts) Print_fish_global(args docopt.Opts) {
+    names := d.Shell_global_names(args)
+
+    scope := "-g"
+    if d.Exit_function {
+        scope = "-l"
+    }
+    export_scope := scope
+    if d.Export {
+        export_scope += "x"
+    }
+
+    var out_buf string
+    for _, key := range d.Ordered_keys(args) {
+        val_arr, is_array := args[key].([]string)
+        value := To_fish(args[key])
+        if value != "" {
+            value = " " + value
+        }
+        if !is_array {
+            out_buf += fmt.Sprintf("set %s %s%s\n", export_scope, names[key], value)
+            continue
+        }
+        // fish would export a list joined by spaces, use the encoding of docopts exec
+        out_buf += fmt.Sprintf("set %s %s%s\n", scope, names[key], value)
+        if d.Export && d.Mangle_key {
+            out_buf += fmt.Sprintf("set %s %s %d\n", export_scope, names[key + ",#"], len(val_arr))
+            for index, v := range val_arr {
+                out_buf += fmt.Sprintf("set %s %s %s\n", export_scope, names[fmt.Sprintf("%s,%d", key, index)], To_fish(v))
+            }
+        }
+    }
+
+    // final output
+    fmt.Fprintf(out, "%s", out_buf)
+}
+
+// Human readable name of the shell given to --shell, for messages.
+func Shell_name(shell string) string {
+    if shell == "posix" {
+        return "POSIX sh"
+    }
+    return shell
+}
+
+// Inside fish single quotes, only backslash and single quote are special
+// and escaped by a backslash. Returns s escaped, without the surrounding quotes.
+func Fishquote(s string) string {
+    s = strings.Replace(s, "\\", "\\\\", -1)
+    return strings.Replace(s, "'", "\\'", -1)
+}
+
+// Convert a parsed type to fish words: a list is many words, an empty list no
+// word at all, nil an empty string.
+func To_fish(v interface{}) string {
+    switch v.(type) {
+    case bool:
+        return fmt.Sprintf("%v", v.(bool))
+    case int:
+        return fmt.Sprintf("%d", v.(int))
+    case string:
+        return fmt.Sprintf("'%s'", Fishquote(v.(string)))
+    case []string:
+        arr := v.([]string)
+        words := make([]string, len(arr))
+        for i, e := range arr {
+            words[i] = To_fish(e)
+        }
+        return strings.Join(words, " ")
+    case nil:
+        return "''"
+    }
+    panic(fmt.Sprintf("To_fish():unsuported type: %v for '%v'", reflect.TypeOf(v), v))
+}
@@ -0,0 +1,96 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_fish.go
+//
+package main
+
+import (
+    "testing"
+    "bytes"
+    "errors"
+)
+
+func TestPrint_fish_global(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d := &Docopts{
+        Global_prefix: "",
+        Mangle_key: true,
+        Output_declare: true,
+        Shell: "fish",
+    }
+
+    args := map[string]interface{}{
+        "--counter": 2,
+        "--in": nil,
+        "<x>": []string{},
+        "FILE": []string{"pipo", "it's"},
+    }
+    d.Print_fish_global(args)
+    res := out.(*bytes.Buffer).String()
+    expect := "set -g counter 2\nset -g in ''\nset -g x\nset -g FILE 'pipo' 'it\\'s'\n"
+    if res != expect {
+        t.Errorf("Print_fish_global\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+    out.(*bytes.Buffer).Reset()
+
+    d.Exit_function = true
+    d.Print_fish_global(map[string]interface{}{"cmd": true})
+    res = out.(*bytes.Buffer).String()
+    expect = "set -l cmd true\n"
+    if res != expect {
+        t.Errorf("Print_fish_global with Exit_function\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
+
+func TestFishquote(t *testing.T) {
+    tables := []struct {
+        input string
+        expect string
+    }{
+        {"pipo", "pipo"},
+        {"it's", "it\\'s"},
+        {"a\\b", "a\\\\b"},
+        {"\\'", "\\\\\\'"},
+        {"$HOME \"x\" *", "$HOME \"x\" *"},
+    }
+    for _, table := range tables {
+        if res := Fishquote(table.input); res != table.expect {
+            t.Errorf("Fishquote(%v), got: %v, want: %v", table.input, res, table.expect)
+        }
+    }
+}
+
+func TestTo_fish(t *testing.T) {
+    tables := []struct {
+        input interface{}
+        expect string
+    }{
+        {nil, "''"},
+        {false, "false"},
+        {3, "3"},
+        {"a b", "'a b'"},
+        {[]string{}, ""},
+        {[]string{"a", "b c"}, "'a' 'b c'"},
+    }
+    for _, table := range tables {
+        if res := To_fish(table.input); res != table.expect {
+            t.Errorf("To_fish(%v), got: %v, want: %v", table.input, res, table.expect)
+        }
+    }
+}
+
+func TestBash_fail_source_fish(t *testing.T) {
+    d := &Docopts{Shell: "fish", Exit_function: true}
+    res := d.Bash_fail_source(Error_message(errors.New("it's"), "Usage: p"), true, Exit_usage)
+    expect := "printf '%s\\n' 'error: it\\'s\nUsage: p' >&2\nreturn 64\n"
+    if res != expect {
+        t.Errorf("Bash_fail_source fish\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+    if Reserved_name("status", "fish") == "" {
+        t.Errorf("Reserved_name('status', 'fish') should be reserved")
+    }
+}
@@ -0,0 +1,24 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// fuzz target for the round trip harness of docopts_roundtrip_test.go, requires
+// go 1.18. Run with:
+//   go test -run '^$' -fuzz FuzzRound_trip -fuzztime 5m
+//
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+    "testing"
+)
+
+func FuzzRound_trip(f *testing.F) {
+    for _, values := range Round_trip_values {
+        f.Add(values[0], values[1], values[2])
+    }
+    // each value goes through every output mode and its shell
+    f.Fuzz(func(t *testing.T, one string, opt string, arg string) {
+        round_trip(t, []string{one, opt, arg})
+    })
+}
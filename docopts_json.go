@@ -0,0 +1,204 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_json.go: JSON API, query a result stored by docopts --json.
+// See API_proposal.md
+//
+package main
+
+import (
+    "fmt"
+    "encoding/json"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// default name of the environment variable holding the JSON result
+const Json_env_default = "DOCOPTS_JSON"
+
+// Find the name of the environment variable holding the JSON result.
+// Order of precedence: --env <name>, $DOCOPTS_JSON_VAR, DOCOPTS_JSON.
+func Json_env_name(env_opt string) string {
+    if env_opt != "" {
+        return env_opt
+    }
+    if name := os.Getenv("DOCOPTS_JSON_VAR"); name != "" {
+        return name
+    }
+    return Json_env_default
+}
+
+// Read and decode the JSON result stored in the environment variable name.
+func Load_json_env(name string) (map[string]interface{}, error) {
+    raw, found := os.LookupEnv(name)
+    if !found {
+        return nil, fmt.Errorf("environment variable %s is not set, did you export it?", name)
+    }
+    return From_json(raw)
+}
+
+// Decode a JSON object produced by To_json(). Numbers are converted back
+// to int, arrays to []string, so values have the same types as returned by
+// docopt.ParseArgs().
+func From_json(raw string) (map[string]interface{}, error) {
+    var args map[string]interface{}
+    dec := json.NewDecoder(strings.NewReader(raw))
+    dec.UseNumber()
+    if err := dec.Decode(&args); err != nil {
+        return nil, fmt.Errorf("cannot decode JSON: %v", err)
+    }
+
+    for k, v := range args {
+        switch v.(type) {
+        case json.Number:
+            i, err := strconv.Atoi(v.(json.Number).String())
+            if err != nil {
+                return nil, fmt.Errorf("'%s': not an integer: %v", k, v)
+            }
+            args[k] = i
+        case []interface{}:
+            arr := v.([]interface{})
+            str_arr := make([]string, len(arr))
+            for i, e := range arr {
+                s, ok := e.(string)
+                if !ok {
+                    return nil, fmt.Errorf("'%s': not an array of string: %v", k, v)
+                }
+                str_arr[i] = s
+            }
+            args[k] = str_arr
+        }
+    }
+    return args, nil
+}
+
+// fetch a key, unknown keys are an error: most likely a typo in the caller
+func json_lookup(args map[string]interface{}, key string) (interface{}, error) {
+    value, found := args[key]
+    if !found {
+        return nil, fmt.Errorf("key not found: '%s'", key)
+    }
+    return value, nil
+}
+
+// Output the value of key for `docopts get`. Arrays are output one element per
+// line, or a single element if index is given. null is output as nothing.
+func Json_get(args map[string]interface{}, key string, index string) error {
+    value, err := json_lookup(args, key)
+    if err != nil {
+        return err
+    }
+
+    if arr, ok := value.([]string); ok {
+        if index == "" {
+            for _, e := range arr {
+                fmt.Fprintf(out, "%s\n", e)
+            }
+            return nil
+        }
+        i, err := strconv.Atoi(index)
+        if err != nil || i < 0 || i >= len(arr) {
+            return fmt.Errorf("'%s': index out of range: '%s', length is %d", key, index, len(arr))
+        }
+        fmt.Fprintf(out, "%s\n", arr[i])
+        return nil
+    }
+
+    if index != "" {
+        return fmt.Errorf("'%s': is not an array, cannot use index '%s'", key, index)
+    }
+    if value != nil {
+        fmt.Fprintf(out, "%v\n", value)
+    }
+    return nil
+}
+
+// Tell if key was given on the command line for `docopts has`: true, a non zero
+// counter, a non null string or a non empty array.
+func Json_has(args map[string]interface{}, key string) (bool, error) {
+    n, err := Json_count(args, key)
+    return n > 0, err
+}
+
+// Count the occurrences of key for `docopts count`: array length, counter value,
+// 1 or 0 for booleans and strings.
+func Json_count(args map[string]interface{}, key string) (int, error) {
+    value, err := json_lookup(args, key)
+    if err != nil {
+        return 0, err
+    }
+
+    switch value.(type) {
+    case []string:
+        return len(value.([]string)), nil
+    case int:
+        return value.(int), nil
+    case bool:
+        if value.(bool) {
+            return 1, nil
+        }
+        return 0, nil
+    case string:
+        return 1, nil
+    case nil:
+        return 0, nil
+    }
+    return 0, fmt.Errorf("'%s': unsupported value: %v", key, value)
+}
+
+// exit code of docopts with --json when help or version is requested
+const Exit_help = 42
+
+// key of the JSON payload holding a Json_failure instead of parsed arguments
+const Json_failure_key = "docopts"
+
+// Stored in the JSON payload when parsing did not produce arguments: help or
+// version requested, or usage error. Exit_code is the one intended for the
+// caller, see: docopts fail.
+type Json_failure struct {
+    Status string `json:"status"`
+    Message string `json:"message"`
+    Exit_code int `json:"exit_code"`
+}
+
+// HelpHandler used with --json: the help or error message is stored in the JSON
+// payload, docopts exits Exit_help or Docopts.Json_error_code so the caller can
+// test $? without eval-ing anything.
+func (d *Docopts) HelpHandler_for_json(err error, usage string) {
+    if err != nil {
+        d.Print_json_failure(&Json_failure{
+            Status: "error",
+            Message: Error_message(err, usage),
+            Exit_code: Exit_usage,
+        })
+        os.Exit(d.Json_error_code)
+    } else {
+        // --help or --version found and --no-help was not given
+        d.Print_json_failure(&Json_failure{
+            Status: "help",
+            Message: usage,
+            Exit_code: 0,
+        })
+        os.Exit(Exit_help)
+    }
+}
+
+// Output the JSON payload of a failure, see: Json_get_failure()
+func (d *Docopts) Print_json_failure(failure *Json_failure) {
+    fmt.Fprintf(out, "%s\n", To_json(map[string]interface{}{Json_failure_key: failure}))
+}
+
+// Extract the Json_failure of a payload decoded by From_json(), nil if the
+// payload holds parsed arguments.
+func Json_get_failure(args map[string]interface{}) (*Json_failure, error) {
+    value, ok := args[Json_failure_key].(map[string]interface{})
+    if !ok {
+        return nil, nil
+    }
+
+    var failure Json_failure
+    if err := json.Unmarshal([]byte(To_json(value)), &failure); err != nil {
+        return nil, fmt.Errorf("cannot decode '%s': %v", Json_failure_key, err)
+    }
+    return &failure, nil
+}
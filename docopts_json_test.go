@@ -0,0 +1,177 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_json.go
+//
+package main
+
+import (
+    "testing"
+    "reflect"
+    "bytes"
+    "os"
+)
+
+func TestJson_env_name(t *testing.T) {
+    bak, found := os.LookupEnv("DOCOPTS_JSON_VAR")
+    defer func() {
+        if found {
+            os.Setenv("DOCOPTS_JSON_VAR", bak)
+        } else {
+            os.Unsetenv("DOCOPTS_JSON_VAR")
+        }
+    }()
+
+    os.Unsetenv("DOCOPTS_JSON_VAR")
+    if name := Json_env_name(""); name != "DOCOPTS_JSON" {
+        t.Errorf("Json_env_name default, got: %v, want: DOCOPTS_JSON", name)
+    }
+    os.Setenv("DOCOPTS_JSON_VAR", "SOME_JSON")
+    if name := Json_env_name(""); name != "SOME_JSON" {
+        t.Errorf("Json_env_name DOCOPTS_JSON_VAR, got: %v, want: SOME_JSON", name)
+    }
+    if name := Json_env_name("OTHER"); name != "OTHER" {
+        t.Errorf("Json_env_name --env, got: %v, want: OTHER", name)
+    }
+}
+
+func TestFrom_json(t *testing.T) {
+    input := map[string]interface{}{
+        "FILE": []string{"pipo", "molo"},
+        "--counter": 2,
+        "--num": "2",
+        "--out": nil,
+        "cmd": true,
+        "EMPTY": []string{},
+    }
+
+    res, err := From_json(To_json(input))
+    if err != nil {
+        t.Fatalf("From_json error: %v", err)
+    }
+    if !reflect.DeepEqual(res, input) {
+        t.Errorf("From_json round trip\ngot: '%v'\nwant: '%v'\n", res, input)
+    }
+
+    for _, bad := range []string{"", "[1, 2]", `{"a": 1.5}`, `{"a": [1]}`} {
+        if _, err := From_json(bad); err == nil {
+            t.Errorf("From_json for '%s' must fail", bad)
+        }
+    }
+}
+
+func TestJson_get(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    args := map[string]interface{}{
+        "FILE": []string{"pipo", "molo toto"},
+        "--counter": 2,
+        "--out": nil,
+        "--code": "it's",
+        "cmd": false,
+    }
+
+    tables := []struct {
+        key string
+        index string
+        expect string
+        fail bool
+    }{
+        {"FILE", "", "pipo\nmolo toto\n", false},
+        {"FILE", "1", "molo toto\n", false},
+        {"FILE", "2", "", true},
+        {"FILE", "-1", "", true},
+        {"--counter", "", "2\n", false},
+        {"--counter", "0", "", true},
+        {"--out", "", "", false},
+        {"--code", "", "it's\n", false},
+        {"cmd", "", "false\n", false},
+        {"--cod", "", "", true},
+    }
+
+    for _, table := range tables {
+        err := Json_get(args, table.key, table.index)
+        res := out.(*bytes.Buffer).String()
+        if table.fail && err == nil {
+            t.Errorf("Json_get for '%s' '%s' must fail", table.key, table.index)
+        }
+        if res != table.expect {
+            t.Errorf("Json_get for '%s' '%s'\ngot: '%v'\nwant: '%v'\n", table.key, table.index, res, table.expect)
+        }
+        out.(*bytes.Buffer).Reset()
+    }
+}
+
+func TestJson_count(t *testing.T) {
+    args := map[string]interface{}{
+        "FILE": []string{"pipo", "molo"},
+        "EMPTY": []string{},
+        "--counter": 3,
+        "--out": nil,
+        "--code": "",
+        "cmd": true,
+        "other": false,
+    }
+
+    tables := []struct {
+        key string
+        expect int
+        has bool
+    }{
+        {"FILE", 2, true},
+        {"EMPTY", 0, false},
+        {"--counter", 3, true},
+        {"--out", 0, false},
+        {"--code", 1, true},
+        {"cmd", 1, true},
+        {"other", 0, false},
+    }
+
+    for _, table := range tables {
+        n, err := Json_count(args, table.key)
+        if err != nil || n != table.expect {
+            t.Errorf("Json_count for '%s', got: %v %v, want: %v", table.key, n, err, table.expect)
+        }
+        has, err := Json_has(args, table.key)
+        if err != nil || has != table.has {
+            t.Errorf("Json_has for '%s', got: %v %v, want: %v", table.key, has, err, table.has)
+        }
+    }
+
+    if _, err := Json_count(args, "nope"); err == nil {
+        t.Errorf("Json_count for unknown key must fail")
+    }
+}
+
+func TestJson_get_failure(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d := &Docopts{}
+    failure := &Json_failure{Status: "error", Message: "error: <x>\nUsage: p", Exit_code: 64}
+    d.Print_json_failure(failure)
+    res := out.(*bytes.Buffer).String()
+    expect := `{"docopts":{"status":"error","message":"error: <x>\nUsage: p","exit_code":64}}` + "\n"
+    if res != expect {
+        t.Errorf("Print_json_failure\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+
+    args, err := From_json(res)
+    if err != nil {
+        t.Fatalf("From_json error: %v", err)
+    }
+    f, err := Json_get_failure(args)
+    if err != nil || f == nil || *f != *failure {
+        t.Errorf("Json_get_failure, got: %v %v, want: %v", f, err, failure)
+    }
+
+    // a command named docopts is not a failure
+    f, err = Json_get_failure(map[string]interface{}{"docopts": true})
+    if err != nil || f != nil {
+        t.Errorf("Json_get_failure for parsed arguments, got: %v %v, want: nil", f, err)
+    }
+}
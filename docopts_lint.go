@@ -0,0 +1,361 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_lint.go: report the mistakes of a help message which docopt ignores
+// or only reports at runtime, see: docopts lint
+//
+package main
+
+import (
+    "fmt"
+    "io/ioutil"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+type Lint_severity int
+
+const (
+    Lint_warning Lint_severity = iota + 1
+    Lint_error
+)
+
+func (s Lint_severity) String() string {
+    if s == Lint_error {
+        return "error"
+    }
+    return "warning"
+}
+
+// A mistake found in the help message. Line and Column start at 1, Line is 0
+// when the issue is about the whole input, like a missing file.
+type Lint_issue struct {
+    Line int
+    Column int
+    Severity Lint_severity
+    Message string
+}
+
+// The issue as 'name:line:column: severity: message', like a compiler.
+func (i Lint_issue) Format(name string) string {
+    if i.Line == 0 {
+        return fmt.Sprintf("%s: %s: %s", name, i.Severity, i.Message)
+    }
+    return fmt.Sprintf("%s:%d:%d: %s: %s", name, i.Line, i.Column, i.Severity, i.Message)
+}
+
+// Exit code of docopts lint: 0 without issue, 1 if only warnings were found,
+// 2 for errors.
+func Lint_status(issues []Lint_issue) int {
+    status := 0
+    for _, i := range issues {
+        if int(i.Severity) > status {
+            status = int(i.Severity)
+        }
+    }
+    return status
+}
+
+// An option description of an Options: section, found at Line and Column.
+type lint_option struct {
+    Line int
+    Column int
+    // index of the Options: section
+    Section int
+    Option *Pattern
+    // the lines of the description, the first one starts with the option
+    Lines []string
+}
+
+// The lines of doc with their indentation, used to locate the issues.
+type lint_doc struct {
+    lines []string
+    issues []Lint_issue
+}
+
+func (l *lint_doc) add(line, column int, severity Lint_severity, format string, a ...interface{}) {
+    l.issues = append(l.issues, Lint_issue{line, column, severity, fmt.Sprintf(format, a...)})
+}
+
+// Line indexes of a section: its header containing name, like 'usage:', and
+// the following indented lines, same as parse_section().
+func (l *lint_doc) sections(name string) [][2]int {
+    header := regexp.MustCompile(`(?i)` + name)
+    result := [][2]int{}
+    for i := 0; i < len(l.lines); i++ {
+        if !header.MatchString(l.lines[i]) {
+            continue
+        }
+        end := i + 1
+        for end < len(l.lines) && lint_indented(l.lines[end]) {
+            end++
+        }
+        result = append(result, [2]int{i, end})
+        i = end - 1
+    }
+    return result
+}
+
+func lint_indented(line string) bool {
+    return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+func lint_indent(line string) string {
+    return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// Check the indentation of the lines of a section: tabs and spaces mixed, and
+// a following line which is not indented, ending the section. looks_inside
+// tells if such a line was meant to be part of the section.
+func (l *lint_doc) check_section(section [2]int, title string, looks_inside func(string) bool) {
+    for i := section[0] + 1; i < section[1]; i++ {
+        if indent := lint_indent(l.lines[i]); strings.Contains(indent, " ") && strings.Contains(indent, "\t") {
+            l.add(i+1, 1, Lint_warning, "indentation mixes tabs and spaces")
+        }
+    }
+    if end := section[1]; end < len(l.lines) && looks_inside(l.lines[end]) {
+        l.add(end+1, 1, Lint_warning, "line not indented: it ends the %s section and is ignored", title)
+    }
+}
+
+// Parse the option descriptions of the Options: sections, as parse_defaults().
+func (l *lint_doc) options(sections [][2]int) []lint_option {
+    options := []lint_option{}
+    for n, section := range sections {
+        var current *lint_option
+        for i := section[0] + 1; i < section[1]; i++ {
+            text := strings.TrimSpace(l.lines[i])
+            if strings.HasPrefix(text, "-") {
+                options = append(options, lint_option{Line: i + 1,
+                    Column: len(lint_indent(l.lines[i])) + 1, Section: n})
+                current = &options[len(options)-1]
+            }
+            if current != nil {
+                current.Lines = append(current.Lines, l.lines[i])
+            }
+        }
+    }
+    for i := range options {
+        options[i].Option = parse_option(strings.Join(options[i].Lines, "\n"))
+    }
+    return options
+}
+
+// Position of the first occurrence of the usage element name in the lines of
+// section, the section header if not found.
+func (l *lint_doc) find(section [2]int, name string) (int, int) {
+    line, column, _ := l.search(section, name)
+    return line, column
+}
+
+// Same as find(), false if name is not found.
+func (l *lint_doc) search(section [2]int, name string) (int, int, bool) {
+    re := regexp.MustCompile(`(^|[\s\[(|:])(` + regexp.QuoteMeta(name) + `)($|[\s\])|.=])`)
+    for i := section[0]; i < section[1]; i++ {
+        if m := re.FindStringSubmatchIndex(l.lines[i]); m != nil {
+            return i + 1, m[4] + 1, true
+        }
+    }
+    return section[0] + 1, 1, false
+}
+
+// Whether the usage section mentions the option o, or [options].
+func (l *lint_doc) mentioned(section [2]int, o *Pattern) bool {
+    for _, name := range []string{"[options]", o.Short, o.Long} {
+        if _, _, found := l.search(section, name); name != "" && found {
+            return true
+        }
+    }
+    return false
+}
+
+// Position of a usage parse error about the option name: an option described
+// more than once is ambiguous from its second description, otherwise the error
+// is about its use in the usage section.
+func (l *lint_doc) option_error_position(section [2]int, options []lint_option, name string) (int, int) {
+    described := 0
+    for _, o := range options {
+        if o.Option.Short == name || o.Option.Long == name {
+            described++
+            if described == 2 {
+                return o.Line, o.Column
+            }
+        }
+    }
+    return l.find(section, name)
+}
+
+// Check the help message doc, the names are mangled with the settings of d.
+// Issues are sorted by position.
+func Lint_usage(doc string, d *Docopts) []Lint_issue {
+    l := &lint_doc{lines: strings.Split(strings.Replace(doc, "\r\n", "\n", -1), "\n")}
+
+    usages := l.sections("usage:")
+    if len(usages) == 0 {
+        l.add(0, 0, Lint_error, `"usage:" (case-insensitive) not found`)
+        return l.issues
+    }
+    if len(usages) > 1 {
+        l.add(usages[1][0]+1, 1, Lint_error, `more than one "usage:" (case-insensitive)`)
+    }
+    usage_section := usages[0]
+    options_sections := l.sections("options:")
+    options := l.options(options_sections)
+
+    u, err := Parse_usage(doc)
+    prog := ""
+    if err != nil && len(usages) == 1 {
+        line, column := usage_section[0]+1, 1
+        if option_err, ok := err.(*Usage_option_error); ok {
+            line, column = l.option_error_position(usage_section, options, option_err.Name)
+        }
+        l.add(line, column, Lint_error, "%v", err)
+    } else if err == nil {
+        prog = u.Prog
+    }
+    l.check_section(usage_section, "Usage:", func(line string) bool {
+        return prog != "" && (line == prog || strings.HasPrefix(line, prog+" "))
+    })
+
+    for _, section := range options_sections {
+        l.check_section(section, "Options:", func(line string) bool {
+            return strings.HasPrefix(line, "-")
+        })
+    }
+
+    described := map[string]bool{}
+    for i, o := range options {
+        opt := o.Option
+        for _, name := range []string{opt.Short, opt.Long} {
+            if name == "" {
+                continue
+            }
+            if described[name] {
+                l.add(o.Line, o.Column, Lint_error, "%s is described more than once", name)
+            }
+            described[name] = true
+        }
+
+        if i > 0 && options[i-1].Section == o.Section && o.Column > options[i-1].Column {
+            l.add(o.Line, o.Column, Lint_warning,
+                "line starting with '-' is parsed as a new option, not as the description of %s",
+                options[i-1].Option.Name)
+        }
+
+        head, _, _ := partition(strings.TrimSpace(o.Lines[0]), "  ")
+        // -o FILE, --output=FILE has a single argument
+        words := []string{}
+        for _, w := range strings.Fields(strings.NewReplacer(",", " ", "=", " ").Replace(head)) {
+            if !strings.HasPrefix(w, "-") && (len(words) == 0 || words[len(words)-1] != w) {
+                words = append(words, w)
+            }
+        }
+        if len(words) > 1 {
+            l.add(o.Line, o.Column, Lint_warning,
+                "%s: the description must be separated by at least two spaces, '%s' is taken as its argument",
+                opt.Name, words[len(words)-1])
+        }
+
+        if opt.Argcount == 0 {
+            re := regexp.MustCompile(`(?i)\[default: .*\]`)
+            for j, line := range o.Lines {
+                if m := re.FindStringIndex(line); m != nil {
+                    l.add(o.Line+j, m[0]+1, Lint_warning,
+                        "%s takes no argument, its [default: ...] value is ignored", opt.Name)
+                    break
+                }
+            }
+        }
+    }
+
+    // without patterns, an option is used if the usage mentions it
+    var leaves []*Pattern
+    if u != nil {
+        leaves = u.Leaves()
+    }
+    for _, o := range options {
+        if Match(`^(-h|--help|--version)$`, o.Option.Name) {
+            continue
+        }
+        if u != nil && find_pattern(leaves, o.Option) != nil || u == nil && l.mentioned(usage_section, o.Option) {
+            continue
+        }
+        l.add(o.Line, o.Column, Lint_warning,
+            "%s is described but not used by any pattern, add it or [options] to the usage", o.Option.Name)
+    }
+
+    if u != nil {
+        mangled := true
+        keys := []string{}
+        for _, leaf := range leaves {
+            if leaf.Name == "-" || leaf.Name == "--" {
+                continue
+            }
+            keys = append(keys, leaf.Name)
+            if _, err := d.Name_mangle(leaf.Name); err != nil {
+                mangled = false
+                line, column := l.find(usage_section, leaf.Name)
+                for _, o := range options {
+                    if leaf.Type == Pattern_option && same_pattern(o.Option, leaf) {
+                        line, column = o.Line, o.Column
+                    }
+                }
+                l.add(line, column, Lint_warning, "%s: %v", leaf.Name, err)
+            }
+        }
+        if mangled {
+            if _, err := d.Mangle_keys(keys); err != nil {
+                l.add(usage_section[0]+1, 1, Lint_warning, "%v", err)
+            }
+        }
+    }
+
+    return Sort_issues(l.issues)
+}
+
+// Sort issues by position, the ones about the whole input first.
+func Sort_issues(issues []Lint_issue) []Lint_issue {
+    sort.SliceStable(issues, func(i, j int) bool {
+        a, b := issues[i], issues[j]
+        return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+    })
+    return issues
+}
+
+// Check the help message of the script at path, read as --from-script: the
+// positions of the issues are the ones in the script.
+func Lint_script(path string, marker string, d *Docopts) []Lint_issue {
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        return []Lint_issue{{Severity: Lint_error, Message: fmt.Sprintf("--from-script: %v", err)}}
+    }
+    usage, _ := Parse_script(string(content), marker, "")
+    if usage == "" {
+        return []Lint_issue{{Severity: Lint_error, Message: Script_usage_error(path, marker).Error()}}
+    }
+
+    // the usage lines are found in order among the commented lines
+    lines := strings.Split(strings.Replace(string(content), "\r\n", "\n", -1), "\n")
+    positions := [][2]int{}
+    next := 0
+    for _, text := range strings.Split(usage, "\n") {
+        position := [2]int{0, 0}
+        for i := next; i < len(lines); i++ {
+            if uncommented, ok := script_uncomment(lines[i], marker); ok && uncommented == text {
+                position = [2]int{i + 1, len(lines[i]) - len(text)}
+                next = i + 1
+                break
+            }
+        }
+        positions = append(positions, position)
+    }
+
+    issues := Lint_usage(usage, d)
+    for i, issue := range issues {
+        if issue.Line > 0 && issue.Line <= len(positions) && positions[issue.Line-1][0] > 0 {
+            issues[i].Line = positions[issue.Line-1][0]
+            issues[i].Column += positions[issue.Line-1][1]
+        }
+    }
+    return issues
+}
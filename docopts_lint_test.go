@@ -0,0 +1,126 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_lint.go
+//
+package main
+
+import (
+    "testing"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+func lint_lines(issues []Lint_issue, name string) string {
+    lines := []string{}
+    for _, issue := range issues {
+        lines = append(lines, issue.Format(name))
+    }
+    return strings.Join(lines, "\n")
+}
+
+func TestLint_usage(t *testing.T) {
+    d := &Docopts{Mangle_key: true, Reserved: "error"}
+    doc := `Usage:
+  prog [-v] <path> [--force] [--output=FILE]
+prog --bad
+
+Options:
+  -v --verbose  Be verbose.
+  -o FILE, --output=FILE  Output file.
+  --force       Force [default: yes].
+  --quiet Be quiet.
+  -h --help     Show this screen.
+  --long        Description with
+                --dash continuation.
+	  --mixed   Mixed.
+-x   not indented.
+`
+    expect := `<help>:2:13: warning: <path>: '<path>' => 'path' would overwrite the shell variable PATH, use -G <prefix> or --reserved=prefix
+<help>:3:1: warning: line not indented: it ends the Usage: section and is ignored
+<help>:8:23: warning: --force takes no argument, its [default: ...] value is ignored
+<help>:9:3: warning: --quiet: the description must be separated by at least two spaces, 'quiet.' is taken as its argument
+<help>:9:3: warning: --quiet is described but not used by any pattern, add it or [options] to the usage
+<help>:11:3: warning: --long is described but not used by any pattern, add it or [options] to the usage
+<help>:12:17: warning: line starting with '-' is parsed as a new option, not as the description of --long
+<help>:12:17: warning: --dash is described but not used by any pattern, add it or [options] to the usage
+<help>:13:1: warning: indentation mixes tabs and spaces
+<help>:13:4: warning: --mixed is described but not used by any pattern, add it or [options] to the usage
+<help>:14:1: warning: line not indented: it ends the Options: section and is ignored`
+    issues := Lint_usage(doc, d)
+    if got := lint_lines(issues, "<help>"); got != expect {
+        t.Errorf("Lint_usage, got:\n%s\nwant:\n%s", got, expect)
+    }
+    if Lint_status(issues) != 1 {
+        t.Errorf("Lint_status of warnings, got: %d, want: 1", Lint_status(issues))
+    }
+
+    // with a prefix, <path> is mangled to p_path
+    d.Global_prefix = "p"
+    issues = Lint_usage("Usage: prog [options] <path>\n\nOptions:\n  -o FILE, --output=FILE  Output.", d)
+    if len(issues) != 0 || Lint_status(issues) != 0 {
+        t.Errorf("Lint_usage without issue, got:\n%s", lint_lines(issues, "<help>"))
+    }
+
+    d.Global_prefix = ""
+    tables := map[string]string{
+        "no usage": `<help>: error: "usage:" (case-insensitive) not found`,
+        "Usage: prog (a\n": `<help>:1:1: error: unmatched '(', expected: ')' got: ''`,
+        "Usage: prog\n\nusage: again": `<help>:3:1: error: more than one "usage:" (case-insensitive)`,
+        "Usage: prog <a-b> <a_b>": `<help>:1:1: warning: mangled names collision: '<a-b>', '<a_b>' => 'a_b'`,
+        "Usage: prog [options]\n\nOptions:\n  -v  Verbose.\n  -v, --very  Very.": "<help>:5:3: error: -v is described more than once",
+        // parse errors at the option, the section checks go on without patterns
+        "Usage: prog -v\n\nOptions:\n  -v  Verbose.\n  -v, --very  Very.\n  --never  Never.": "<help>:5:3: error: -v is specified ambiguously 2 times\n" +
+            "<help>:5:3: error: -v is described more than once\n" +
+            "<help>:6:3: warning: --never is described but not used by any pattern, add it or [options] to the usage",
+        "Usage: prog [-v] --out=x\n\nOptions:\n  --out  Out.": "<help>:1:18: error: --out must not have an argument",
+        "Usage: prog (a\n\nOptions:\n  -v  Verbose.": "<help>:1:1: error: unmatched '(', expected: ')' got: ''\n" +
+            "<help>:4:3: warning: -v is described but not used by any pattern, add it or [options] to the usage",
+        "Usage: prog (a [options]\n\nOptions:\n  -v  Verbose.": `<help>:1:1: error: unmatched '(', expected: ')' got: ''`,
+    }
+    for doc, expect := range tables {
+        if got := lint_lines(Lint_usage(doc, d), "<help>"); got != expect {
+            t.Errorf("Lint_usage(%q), got:\n%s\nwant:\n%s", doc, got, expect)
+        }
+    }
+
+    issues = []Lint_issue{{1, 1, Lint_warning, "w"}, {2, 1, Lint_error, "e"}}
+    if Lint_status(issues) != 2 {
+        t.Errorf("Lint_status with an error, got: %d, want: 2", Lint_status(issues))
+    }
+}
+
+func TestLint_script(t *testing.T) {
+    dir, err := ioutil.TempDir("", "docopts_lint")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    d := &Docopts{Mangle_key: true, Reserved: "error"}
+    path := filepath.Join(dir, "prog.sh")
+    script := "#!/bin/bash\n# Prog.\n#\n# Usage:\n#   prog [--force]\n#\n# Options:\n#   --force  Force [default: yes].\n"
+    ioutil.WriteFile(path, []byte(script), 0644)
+    expect := path + ":8:20: warning: --force takes no argument, its [default: ...] value is ignored"
+    if got := lint_lines(Lint_script(path, "#", d), path); got != expect {
+        t.Errorf("Lint_script, got:\n%s\nwant:\n%s", got, expect)
+    }
+
+    script = "#!/bin/bash\n##? Usage: prog <a>\necho\n##?\n##? Options:\n##?   -v  [default: 1]\n"
+    ioutil.WriteFile(path, []byte(script), 0644)
+    expect = path + ":6:7: warning: -v is described but not used by any pattern, add it or [options] to the usage\n" +
+        path + ":6:11: warning: -v takes no argument, its [default: ...] value is ignored"
+    if got := lint_lines(Lint_script(path, "##?", d), path); got != expect {
+        t.Errorf("Lint_script with a marker, got:\n%s\nwant:\n%s", got, expect)
+    }
+
+    issues := Lint_script(path, "#", d)
+    if len(issues) != 1 || issues[0].Line != 0 || issues[0].Severity != Lint_error {
+        t.Errorf("Lint_script without usage, got: %v", issues)
+    }
+    issues = Lint_script(filepath.Join(dir, "none"), "#", d)
+    if len(issues) != 1 || !strings.HasPrefix(issues[0].Message, "--from-script: open ") {
+        t.Errorf("Lint_script of a missing file, got: %v", issues)
+    }
+}
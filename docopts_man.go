@@ -0,0 +1,284 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_man.go: roff man page generated from the help message, see:
+// --generate-man
+//
+package main
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// the tokens of a usage line: <argument>, word, blanks or any other character
+var roff_usage_tokens = regexp.MustCompile(`<[^>]*>|[\w.-]+|\s+|.`)
+
+// A part of the help message: Title is the text before ':' of a section
+// header like 'Options:' or 'Usage:', empty for the free text between
+// sections. Lines don't include the header, except the text following
+// 'Usage:' on the same line.
+type Man_section struct {
+    Title string
+    Lines []string
+}
+
+// Split the help message into sections. A section starts at a non indented
+// line ending with ':', or containing 'usage:', and goes on up to the next non
+// indented line.
+func Man_sections(doc string) []Man_section {
+    sections := []Man_section{}
+    var current *Man_section
+    for _, line := range strings.Split(doc, "\n") {
+        line = strings.TrimRight(line, " \t\r")
+        indented := line == "" || line[0] == ' ' || line[0] == '\t'
+        if !indented {
+            title, _, rest := partition(line, ":")
+            if Match(`(?i)usage:`, line) || strings.HasSuffix(line, ":") {
+                sections = append(sections, Man_section{Title: strings.TrimSpace(title)})
+                current = &sections[len(sections)-1]
+                if strings.TrimSpace(rest) != "" {
+                    current.Lines = append(current.Lines, rest)
+                }
+                continue
+            }
+            if current == nil || current.Title != "" {
+                sections = append(sections, Man_section{})
+                current = &sections[len(sections)-1]
+            }
+        } else if current == nil {
+            sections = append(sections, Man_section{})
+            current = &sections[len(sections)-1]
+        }
+        current.Lines = append(current.Lines, line)
+    }
+    return sections
+}
+
+// Escape text for roff: backslashes, hyphens which are not hyphenation points
+// in option names, and a leading control character.
+func Roff_escape(s string) string {
+    return roff_line(roff_chars(s))
+}
+
+func roff_chars(s string) string {
+    s = strings.Replace(s, `\`, `\e`, -1)
+    return strings.Replace(s, "-", `\-`, -1)
+}
+
+// a text line starting with . or ' would be a request
+func roff_line(s string) string {
+    if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+        return `\&` + s
+    }
+    return s
+}
+
+// Escape and highlight usage text: options and prog in bold, arguments like
+// <file> or FILE in italic.
+func Roff_usage(s string, prog string) string {
+    var result strings.Builder
+    for i, tok := range roff_usage_tokens.FindAllString(s, -1) {
+        escaped := roff_chars(tok)
+        switch {
+        case strings.HasPrefix(tok, "<") || IsUpper(tok) && Match(`^[A-Z]`, tok):
+            result.WriteString(`\fI` + escaped + `\fR`)
+        case strings.HasPrefix(tok, "-") && tok != "-" && tok != "--" || i == 0 && tok == prog:
+            result.WriteString(`\fB` + escaped + `\fR`)
+        default:
+            result.WriteString(escaped)
+        }
+    }
+    return roff_line(result.String())
+}
+
+// Remove the indentation common to all non blank lines.
+func man_dedent(lines []string) []string {
+    indent := -1
+    for _, line := range lines {
+        if strings.TrimSpace(line) == "" {
+            continue
+        }
+        n := len(line) - len(strings.TrimLeft(line, " \t"))
+        if indent == -1 || n < indent {
+            indent = n
+        }
+    }
+    result := make([]string, len(lines))
+    for i, line := range lines {
+        if len(line) >= indent && indent > 0 {
+            line = line[indent:]
+        }
+        result[i] = line
+    }
+    return result
+}
+
+// Split lines into paragraphs separated by blank lines.
+func man_paragraphs(lines []string) [][]string {
+    paragraphs := [][]string{}
+    paragraph := []string{}
+    for _, line := range append(lines, "") {
+        if strings.TrimSpace(line) != "" {
+            paragraph = append(paragraph, line)
+            continue
+        }
+        if len(paragraph) > 0 {
+            paragraphs = append(paragraphs, paragraph)
+            paragraph = []string{}
+        }
+    }
+    return paragraphs
+}
+
+// Free text: filled paragraphs, indented paragraphs are kept as is.
+func man_text(lines []string) string {
+    var result strings.Builder
+    for _, paragraph := range man_paragraphs(lines) {
+        if paragraph[0][0] == ' ' || paragraph[0][0] == '\t' {
+            result.WriteString(".PP\n.RS\n.nf\n")
+            for _, line := range man_dedent(paragraph) {
+                result.WriteString(Roff_escape(line) + "\n")
+            }
+            result.WriteString(".fi\n.RE\n")
+            continue
+        }
+        result.WriteString(".PP\n")
+        for _, line := range paragraph {
+            result.WriteString(Roff_escape(strings.TrimSpace(line)) + "\n")
+        }
+    }
+    return result.String()
+}
+
+// Two columns entries, like options or commands with their description: a
+// line at the indentation of the first one starts an entry, the text after
+// two spaces is its description, continued by the more indented lines.
+// Returns false if the lines are not two columns entries: the first line
+// must have a description, unless options is true, where a long option can
+// have its description on the following lines.
+func man_entries(lines []string, prog string, options bool) (string, bool) {
+    lines = man_dedent(lines)
+    first := `^\S.*?\S\s{2,}\S`
+    if options {
+        first = `^-`
+    }
+    if len(lines) == 0 || !Match(first, lines[0]) {
+        return "", false
+    }
+    var result strings.Builder
+    for _, line := range lines {
+        if strings.TrimSpace(line) == "" {
+            continue
+        }
+        if line[0] == ' ' || line[0] == '\t' {
+            result.WriteString(Roff_escape(strings.TrimSpace(line)) + "\n")
+            continue
+        }
+        term, _, description := partition(line, "  ")
+        result.WriteString(".TP\n" + Roff_usage(strings.TrimSpace(term), prog) + "\n")
+        if description = strings.TrimSpace(description); description != "" {
+            result.WriteString(Roff_escape(description) + "\n")
+        }
+    }
+    return result.String(), true
+}
+
+// The date of the man page, from SOURCE_DATE_EPOCH as YYYY-MM-DD in UTC, see:
+// https://reproducible-builds.org/specs/source-date-epoch/
+// Empty if it is not set: the page is the same whenever it is generated.
+func Man_date() (string, error) {
+    epoch := os.Getenv("SOURCE_DATE_EPOCH")
+    if epoch == "" {
+        return "", nil
+    }
+    seconds, err := strconv.ParseInt(epoch, 10, 64)
+    if err != nil {
+        return "", fmt.Errorf("SOURCE_DATE_EPOCH: '%s' is not a number of seconds", epoch)
+    }
+    return time.Unix(seconds, 0).UTC().Format("2006-01-02"), nil
+}
+
+// The man page of the help message: NAME and DESCRIPTION come from the text
+// before the Usage: section, SYNOPSIS from the usage lines, followed by the
+// other sections in order. The first line of version is the footer, the date
+// comes from Man_date().
+func Man_page(doc, version string) (string, error) {
+    usage, err := Parse_usage(doc)
+    if err != nil {
+        return "", err
+    }
+    prog := usage.Prog
+    date, err := Man_date()
+    if err != nil {
+        return "", err
+    }
+
+    var page strings.Builder
+    footer, _, _ := partition(strings.TrimSpace(version), "\n")
+    // without a date the field is left empty, roff has no way to skip it and
+    // still give the footer
+    fmt.Fprintf(&page, ".TH %s 1 \"%s\" \"%s\"\n", Roff_escape(strings.ToUpper(prog)), date,
+        strings.Replace(Roff_escape(footer), `"`, `\(dq`, -1))
+
+    sections := Man_sections(doc)
+    var name string
+    var synopsis, description, others strings.Builder
+    for _, s := range sections {
+        title := strings.ToUpper(s.Title)
+        switch {
+        case s.Title == "" && name == "" && synopsis.Len() == 0:
+            paragraphs := man_paragraphs(s.Lines)
+            if len(paragraphs) == 0 {
+                continue
+            }
+            words := strings.Fields(strings.Join(paragraphs[0], " "))
+            name = Roff_escape(strings.Join(words, " "))
+            rest := []string{}
+            for _, p := range paragraphs[1:] {
+                rest = append(append(rest, p...), "")
+            }
+            description.WriteString(man_text(rest))
+        case s.Title == "":
+            description.WriteString(man_text(s.Lines))
+        case Match(`(?i)usage`, s.Title) && synopsis.Len() == 0:
+            synopsis.WriteString(".nf\n")
+            for _, line := range man_dedent(s.Lines) {
+                if strings.TrimSpace(line) != "" {
+                    synopsis.WriteString(Roff_usage(strings.TrimSpace(line), prog) + "\n")
+                }
+            }
+            synopsis.WriteString(".fi\n")
+        default:
+            entries, ok := man_entries(s.Lines, prog, Match(`(?i)options`, s.Title))
+            if !ok {
+                entries = man_text(s.Lines)
+            }
+            fmt.Fprintf(&others, ".SH %s\n%s", Roff_escape(title), entries)
+        }
+    }
+
+    page.WriteString(".SH NAME\n" + Roff_escape(prog))
+    if name != "" {
+        page.WriteString(` \- ` + name)
+    }
+    page.WriteString("\n.SH SYNOPSIS\n" + synopsis.String())
+    if description.Len() > 0 {
+        page.WriteString(".SH DESCRIPTION\n" + description.String())
+    }
+    page.WriteString(others.String())
+    return page.String(), nil
+}
+
+// Outputs the man page of the help message, see: Man_page()
+func Print_man(doc, version string) error {
+    page, err := Man_page(doc, version)
+    if err != nil {
+        return err
+    }
+    fmt.Fprint(out, page)
+    return nil
+}
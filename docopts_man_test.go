@@ -0,0 +1,148 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_man.go
+//
+package main
+
+import (
+    "testing"
+    "bytes"
+    "fmt"
+    "os"
+)
+
+var Man_usage = `Naval Fate: the game
+of ships and mines.
+
+Ships are moved on a grid.
+
+Usage:
+  naval_fate ship <name> move <x> <y> [--speed=<kn>]
+  naval_fate mine (set|remove) <x> <y> [--moored|--drifting]
+
+Options:
+  -h --help     Show this screen.
+  --speed=<kn>  Speed in knots
+                [default: 10].
+  --moored      Moored (anchored) mine.
+
+Examples:
+  naval_fate ship Guardian move 10 50 \
+    --speed=20
+  .hidden
+`
+
+func TestMan_sections(t *testing.T) {
+    sections := Man_sections(Man_usage)
+    titles := []string{}
+    for _, s := range sections {
+        titles = append(titles, fmt.Sprintf("%s:%d", s.Title, len(s.Lines)))
+    }
+    expect := "[:5 Usage:3 Options:5 Examples:4]"
+    if fmt.Sprint(titles) != expect {
+        t.Errorf("Man_sections, got: %v, want: %v", titles, expect)
+    }
+
+    sections = Man_sections("Usage: prog <x>\n       prog -v")
+    if len(sections) != 1 || fmt.Sprintf("%q", sections[0].Lines) != `[" prog <x>" "       prog -v"]` {
+        t.Errorf("Man_sections with usage on the header line, got: %q", sections)
+    }
+}
+
+func TestRoff_usage(t *testing.T) {
+    tables := map[string]string{
+        "prog ship <name> [--speed=<kn>]": `\fBprog\fR ship \fI<name>\fR [\fB\-\-speed\fR=\fI<kn>\fR]`,
+        "prog -o FILE -- <a-b>...": `\fBprog\fR \fB\-o\fR \fIFILE\fR \-\- \fI<a\-b>\fR...`,
+        "x prog": `x prog`,
+        ".hidden": `\&.hidden`,
+        `back\slash`: `back\eslash`,
+    }
+    for s, expect := range tables {
+        if got := Roff_usage(s, "prog"); got != expect {
+            t.Errorf("Roff_usage for '%s'\ngot: %s\nwant: %s", s, got, expect)
+        }
+    }
+}
+
+func TestMan_date(t *testing.T) {
+    bak, found := os.LookupEnv("SOURCE_DATE_EPOCH")
+    defer func() {
+        if found {
+            os.Setenv("SOURCE_DATE_EPOCH", bak)
+        } else {
+            os.Unsetenv("SOURCE_DATE_EPOCH")
+        }
+    }()
+
+    os.Unsetenv("SOURCE_DATE_EPOCH")
+    if date, err := Man_date(); date != "" || err != nil {
+        t.Errorf("Man_date unset, got: '%s', %v, want: ''", date, err)
+    }
+    os.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+    if date, err := Man_date(); date != "2023-11-14" || err != nil {
+        t.Errorf("Man_date, got: '%s', %v, want: 2023-11-14", date, err)
+    }
+    os.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+    if _, err := Man_date(); err == nil {
+        t.Errorf("Man_date must fail on 'yesterday'")
+    }
+    if _, err := Man_page(Man_usage, ""); err == nil {
+        t.Errorf("Man_page must fail on an invalid SOURCE_DATE_EPOCH")
+    }
+}
+
+func TestPrint_man(t *testing.T) {
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+    bak_epoch, found := os.LookupEnv("SOURCE_DATE_EPOCH")
+    defer func() {
+        if found {
+            os.Setenv("SOURCE_DATE_EPOCH", bak_epoch)
+        }
+    }()
+    os.Unsetenv("SOURCE_DATE_EPOCH")
+
+    if err := Print_man(Man_usage, "Naval Fate 2.0 \"beta\"\nCopyright (C) 2013"); err != nil {
+        t.Fatalf("Print_man error: %v", err)
+    }
+    expect := `.TH NAVAL_FATE 1 "" "Naval Fate 2.0 \(dqbeta\(dq"
+.SH NAME
+naval_fate \- Naval Fate: the game of ships and mines.
+.SH SYNOPSIS
+.nf
+\fBnaval_fate\fR ship \fI<name>\fR move \fI<x>\fR \fI<y>\fR [\fB\-\-speed\fR=\fI<kn>\fR]
+\fBnaval_fate\fR mine (set|remove) \fI<x>\fR \fI<y>\fR [\fB\-\-moored\fR|\fB\-\-drifting\fR]
+.fi
+.SH DESCRIPTION
+.PP
+Ships are moved on a grid.
+.SH OPTIONS
+.TP
+\fB\-h\fR \fB\-\-help\fR
+Show this screen.
+.TP
+\fB\-\-speed\fR=\fI<kn>\fR
+Speed in knots
+[default: 10].
+.TP
+\fB\-\-moored\fR
+Moored (anchored) mine.
+.SH EXAMPLES
+.PP
+.RS
+.nf
+naval_fate ship Guardian move 10 50 \e
+  \-\-speed=20
+\&.hidden
+.fi
+.RE
+`
+    if res := out.(*bytes.Buffer).String(); res != expect {
+        t.Errorf("Print_man\ngot:\n%s\nwant:\n%s", res, expect)
+    }
+
+    if err := Print_man("no usage here", ""); err == nil {
+        t.Errorf("Print_man must fail without usage")
+    }
+}
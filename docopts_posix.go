@@ -0,0 +1,103 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_posix.go: POSIX sh output backend for dash or busybox ash, see: --shell=posix
+//
+package main
+
+import (
+    "fmt"
+    "github.com/docopt/docopt-go"
+    "strings"
+)
+
+// suffix of the variable holding the number of values of a repeatable argument
+const Posix_count_suffix = "_COUNT"
+
+// Performs output for POSIX sh globals: only scalar assignments, as sh has
+// neither associative arrays nor array literals. Repeatable arguments are
+// stored as a count and indexed scalars:
+// FILE_COUNT=length
+// FILE_i=value
+// 'i' is an integer from 0 to length - 1
+// The repeatable argument named by Docopts.Set_positional resets the
+// positional parameters with 'set --' instead.
+func (d *Docopts) Print_posix_global(args docopt.Opts) {
+    if d.Set_positional != "" {
+        if _, ok := args[d.Set_positional].([]string); !ok {
+            docopts_error(fmt.Sprintf("--set-positional: '%s' is not a repeatable argument of the usage", d.Set_positional), nil)
+        }
+    }
+
+    names, err := d.Posix_names(args)
+    if err != nil {
+        docopts_error("%v", err)
+    }
+
+    // dash has no 'local -x', --export takes precedence over --function
+    declare := d.Global_declare()
+
+    var out_buf string
+    for _, key := range d.Ordered_keys(args) {
+        val_arr, ok := args[key].([]string)
+        if !ok {
+            out_buf += fmt.Sprintf("%s%s=%s\n", declare, names[key], To_bash(args[key]))
+            continue
+        }
+        if key == d.Set_positional {
+            out_buf += fmt.Sprintf("set --%s\n", To_posix_words(val_arr))
+            continue
+        }
+        out_buf += fmt.Sprintf("%s%s=%d\n", declare, names[key + ",#"], len(val_arr))
+        for index, v := range val_arr {
+            out_buf += fmt.Sprintf("%s%s=%s\n", declare, names[fmt.Sprintf("%s,%d", key, index)], To_bash(v))
+        }
+    }
+
+    // final output
+    fmt.Fprintf(out, "%s", out_buf)
+}
+
+// Variable names for POSIX globals, see: Global_names(). Repeatable arguments
+// get extra keys using the fake nested layout of Print_bash_args(): 'key,#' for
+// the count and 'key,i' for each value. Generated names must not collide with
+// another mangled name. Keys kept by --no-mangle are not variable names and
+// are refused.
+func (d *Docopts) Posix_names(args docopt.Opts) (map[string]string, error) {
+    if ! d.Mangle_key {
+        return nil, fmt.Errorf("--no-mangle: keys are not variable names, use -G <prefix> or --json")
+    }
+    names, err := d.Mangle_keys(d.Ordered_keys(args))
+    if err != nil {
+        return nil, err
+    }
+
+    for key, value := range args {
+        val_arr, ok := value.([]string)
+        if !ok || key == d.Set_positional {
+            continue
+        }
+        names[key + ",#"] = names[key] + Posix_count_suffix
+        for index := range val_arr {
+            names[fmt.Sprintf("%s,%d", key, index)] = fmt.Sprintf("%s_%d", names[key], index)
+        }
+    }
+
+    collisions := Name_collisions(names)
+    if len(collisions) > 0 {
+        msg := make([]string, len(collisions))
+        for i, group := range collisions {
+            msg[i] = fmt.Sprintf("'%s' => '%s'", strings.Join(group, "', '"), names[group[0]])
+        }
+        return nil, fmt.Errorf("mangled names collision: %s", strings.Join(msg, "; "))
+    }
+    return names, nil
+}
+
+// Convert a []string to a list of shellquoted words, each prefixed by a space.
+func To_posix_words(arr []string) string {
+    var words string
+    for _, e := range arr {
+        words += " " + To_bash(e)
+    }
+    return words
+}
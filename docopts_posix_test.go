@@ -0,0 +1,96 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_posix.go
+//
+package main
+
+import (
+    "testing"
+    "bytes"
+    "errors"
+)
+
+func TestPrint_posix_global(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d := &Docopts{
+        Global_prefix: "",
+        Mangle_key: true,
+        Output_declare: true,
+        Shell: "posix",
+    }
+
+    args := map[string]interface{}{
+        "--counter": 2,
+        "--in": nil,
+        "<x>": []string{},
+        "FILE": []string{"pipo", "it's"},
+    }
+    d.Print_posix_global(args)
+    res := out.(*bytes.Buffer).String()
+    expect := "counter=2\nin=\nx_COUNT=0\nFILE_COUNT=2\nFILE_0='pipo'\nFILE_1='it'\\''s'\n"
+    if res != expect {
+        t.Errorf("Print_posix_global\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+    out.(*bytes.Buffer).Reset()
+
+    d.Set_positional = "FILE"
+    d.Exit_function = true
+    d.Print_posix_global(args)
+    res = out.(*bytes.Buffer).String()
+    expect = "local counter=2\nlocal in=\nlocal x_COUNT=0\nset -- 'pipo' 'it'\\''s'\n"
+    if res != expect {
+        t.Errorf("Print_posix_global with Set_positional\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
+
+func TestPosix_names(t *testing.T) {
+    d := &Docopts{
+        Global_prefix: "ARGS",
+        Mangle_key: true,
+        Shell: "posix",
+    }
+
+    names, err := d.Posix_names(map[string]interface{}{"FILE": []string{"a", "b"}})
+    if err != nil {
+        t.Fatalf("Posix_names error: %v", err)
+    }
+    expect := map[string]string{
+        "FILE": "ARGS_FILE",
+        "FILE,#": "ARGS_FILE_COUNT",
+        "FILE,0": "ARGS_FILE_0",
+        "FILE,1": "ARGS_FILE_1",
+    }
+    for key, name := range expect {
+        if names[key] != name {
+            t.Errorf("Posix_names['%s'], got: %v, want: %v", key, names[key], name)
+        }
+    }
+
+    d.Global_prefix = ""
+    _, err = d.Posix_names(map[string]interface{}{"FILE": []string{"a"}, "<FILE_0>": "b"})
+    want := "mangled names collision: '<FILE_0>', 'FILE,0' => 'FILE_0'"
+    if err == nil || err.Error() != want {
+        t.Errorf("Posix_names collision, got: %v, want: %v", err, want)
+    }
+
+    // no variable name for the count and the values
+    d.Mangle_key = false
+    _, err = d.Posix_names(map[string]interface{}{"FILE": []string{"a"}})
+    want = "--no-mangle: keys are not variable names, use -G <prefix> or --json"
+    if err == nil || err.Error() != want {
+        t.Errorf("Posix_names with --no-mangle, got: %v, want: %v", err, want)
+    }
+}
+
+func TestBash_fail_source_posix(t *testing.T) {
+    d := &Docopts{Shell: "posix"}
+    res := d.Bash_fail_source(Error_message(errors.New("oops"), "Usage: p"), true, Exit_usage)
+    expect := "printf '%s\\n' 'error: oops\nUsage: p' >&2\nexit 64\n"
+    if res != expect {
+        t.Errorf("Bash_fail_source posix\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
@@ -0,0 +1,113 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_quote.go: quoting of the output values, see: --quoting
+//
+package main
+
+import (
+    "fmt"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// Quote a string value according to Docopts.Quoting:
+// single: '...', the legacy quoting, newlines are output as is
+// ansi-c: $'...', every non printable byte is escaped
+// auto: the minimal safe form, bare word, '...' or $'...'
+func (d *Docopts) Quote(s string) string {
+    switch d.Quoting {
+    case "ansi-c":
+        return Ansi_c_quote(s)
+    case "auto":
+        return Auto_quote(s)
+    }
+    return fmt.Sprintf("'%s'", Shellquote(s))
+}
+
+// Same as To_bash(), but string values are quoted with Docopts.Quote().
+func (d *Docopts) To_shell(v interface{}) string {
+    switch v.(type) {
+    case string:
+        return d.Quote(v.(string))
+    case []string:
+        arr := v.([]string)
+        words := make([]string, len(arr))
+        for i, e := range arr {
+            words[i] = d.Quote(e)
+        }
+        return fmt.Sprintf("(%s)", strings.Join(words, " "))
+    }
+    return To_bash(v)
+}
+
+// Quote s as a bash $'...' string. Backslash and single quote are escaped,
+// control characters use their C escape or \xHH, invalid UTF-8 bytes are
+// output as \xHH too, so the result is always a single printable line.
+func Ansi_c_quote(s string) string {
+    var b strings.Builder
+    b.WriteString("$'")
+    for i := 0; i < len(s); {
+        r, size := utf8.DecodeRuneInString(s[i:])
+        if r == utf8.RuneError && size <= 1 {
+            fmt.Fprintf(&b, "\\x%02x", s[i])
+            i++
+            continue
+        }
+        i += size
+        switch r {
+        case '\\':
+            b.WriteString("\\\\")
+        case '\'':
+            b.WriteString("\\'")
+        case '\a':
+            b.WriteString("\\a")
+        case '\b':
+            b.WriteString("\\b")
+        case '\x1b':
+            b.WriteString("\\e")
+        case '\f':
+            b.WriteString("\\f")
+        case '\n':
+            b.WriteString("\\n")
+        case '\r':
+            b.WriteString("\\r")
+        case '\t':
+            b.WriteString("\\t")
+        case '\v':
+            b.WriteString("\\v")
+        default:
+            if unicode.IsPrint(r) {
+                b.WriteRune(r)
+            } else {
+                // escape each byte of the rune, C1 controls, bidi overrides...
+                for _, c := range []byte(string(r)) {
+                    fmt.Fprintf(&b, "\\x%02x", c)
+                }
+            }
+        }
+    }
+    b.WriteString("'")
+    return b.String()
+}
+
+// Quote s with the minimal safe form: a bare word for simple values like
+// file names or numbers, '...' for printable strings, $'...' otherwise.
+func Auto_quote(s string) string {
+    if Match(`^[A-Za-z0-9_@%+:,./-]+$`, s) {
+        return s
+    }
+    if utf8.ValidString(s) {
+        printable := true
+        for _, r := range s {
+            if !unicode.IsPrint(r) {
+                printable = false
+                break
+            }
+        }
+        if printable {
+            return fmt.Sprintf("'%s'", Shellquote(s))
+        }
+    }
+    return Ansi_c_quote(s)
+}
@@ -0,0 +1,110 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_quote.go
+//
+package main
+
+import (
+    "testing"
+    "bytes"
+    "os/exec"
+)
+
+func TestAnsi_c_quote(t *testing.T) {
+    tables := []struct {
+        input string
+        expect string
+    }{
+        {"", "$''"},
+        {"pipo", "$'pipo'"},
+        {"it's", "$'it\\'s'"},
+        {"a\\nb", "$'a\\\\nb'"},
+        {"a\nb\tc", "$'a\\nb\\tc'"},
+        {"\x1b[31mred", "$'\\e[31mred'"},
+        {"\x01\x7f", "$'\\x01\\x7f'"},
+        {"\xff\xfe", "$'\\xff\\xfe'"},
+        {"été", "$'été'"},
+        {"\u202e", "$'\\xe2\\x80\\xae'"},
+    }
+    for _, table := range tables {
+        if res := Ansi_c_quote(table.input); res != table.expect {
+            t.Errorf("Ansi_c_quote(%q), got: %v, want: %v", table.input, res, table.expect)
+        }
+    }
+}
+
+func TestAuto_quote(t *testing.T) {
+    tables := []struct {
+        input string
+        expect string
+    }{
+        {"", "''"},
+        {"file.txt", "file.txt"},
+        {"-v", "-v"},
+        {"a b", "'a b'"},
+        {"*", "'*'"},
+        {"~", "'~'"},
+        {"it's", "'it'\\''s'"},
+        {"été", "'été'"},
+        {"a\nb", "$'a\\nb'"},
+    }
+    for _, table := range tables {
+        if res := Auto_quote(table.input); res != table.expect {
+            t.Errorf("Auto_quote(%q), got: %v, want: %v", table.input, res, table.expect)
+        }
+    }
+}
+
+func TestTo_shell(t *testing.T) {
+    d := &Docopts{Quoting: "auto"}
+    tables := []struct {
+        input interface{}
+        expect string
+    }{
+        {nil, ""},
+        {true, "true"},
+        {2, "2"},
+        {"a\tb", "$'a\\tb'"},
+        {[]string{}, "()"},
+        {[]string{"x", "y z"}, "(x 'y z')"},
+    }
+    for _, table := range tables {
+        if res := d.To_shell(table.input); res != table.expect {
+            t.Errorf("To_shell(%v), got: %v, want: %v", table.input, res, table.expect)
+        }
+    }
+}
+
+// eval the output of each quoting in bash and compare byte for byte
+func TestQuote_round_trip(t *testing.T) {
+    bash, err := exec.LookPath("bash")
+    if err != nil {
+        t.Skip("bash not found")
+    }
+
+    all_bytes := make([]byte, 0, 255)
+    for c := 1; c < 256; c++ {
+        all_bytes = append(all_bytes, byte(c))
+    }
+    values := []string{
+        "", "plain", "a b", "it's", "'", "\\", "\\'", "$HOME `id` $(id)",
+        "a\nb\r\n", "\x1b[1;31mred\x1b[0m", "été \u202e", "\xff\xc3", "!x",
+        string(all_bytes),
+    }
+
+    for _, quoting := range []string{"single", "ansi-c", "auto"} {
+        d := &Docopts{Quoting: quoting}
+        for _, v := range values {
+            cmd := exec.Command(bash, "--norc", "--noprofile", "-c", "printf '%s' " + d.Quote(v))
+            cmd.Env = []string{"LC_ALL=C"}
+            res, err := cmd.Output()
+            if err != nil {
+                t.Errorf("round trip %s %q: %v", quoting, v, err)
+                continue
+            }
+            if !bytes.Equal(res, []byte(v)) {
+                t.Errorf("round trip %s, got: %q, want: %q", quoting, res, v)
+            }
+        }
+    }
+}
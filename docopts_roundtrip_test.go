@@ -0,0 +1,420 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// round trip harness: argv is parsed and printed by docopts, the output is
+// evaled by the shell of each output mode: bash, dash or sh, zsh and fish, the
+// resulting variables must equal argv. The help and error messages go through
+// the same shells. A mode whose shell is not found is skipped. See also:
+// FuzzRound_trip in docopts_fuzz_test.go
+//
+package main
+
+import (
+    "testing"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "github.com/docopt/docopt-go"
+    "os"
+    "os/exec"
+    "strings"
+    "unicode/utf8"
+)
+
+// usage of the harness, argv values are given with --one and --opt only,
+// positional values are prefixed by x so they never look like options.
+const Round_trip_usage = "usage: prog [--one=<v>] [--opt=<v>...] [<args>...]"
+
+// An output mode of docopts: how to print and how to dump the variables back.
+// Shell evaluates the output: bash, posix, zsh or fish, json is decoded in go.
+// The dump script evals $DOCOPTS_CODE, then outputs each value followed by a
+// NUL byte in the order --one, --opt..., <args>...
+type Round_trip_mode struct {
+    Name string
+    Shell string
+    Print func(d *Docopts, args docopt.Opts)
+    Dump string
+}
+
+// dump of the posix output, also used to read the variables exported
+const Round_trip_posix_dump = `printf '%s\0' "$one"
+        for k in opt args ; do
+            eval "n=\$${k}_COUNT"
+            i=0
+            while [ $i -lt $n ] ; do eval "printf '%s\0' \"\$${k}_$i\"" ; i=$((i + 1)) ; done
+        done`
+
+var Round_trip_modes = []Round_trip_mode{
+    {
+        "assoc",
+        "bash",
+        func(d *Docopts, args docopt.Opts) { d.Print_bash_args("ARGS", args) },
+        `eval "$DOCOPTS_CODE"
+        printf '%s\0' "${ARGS[--one]}"
+        for k in --opt '<args>' ; do
+            for ((i = 0; i < ${ARGS[$k,#]}; i++)) ; do printf '%s\0' "${ARGS[$k,$i]}" ; done
+        done`,
+    },
+    {
+        "assoc native arrays",
+        "bash",
+        func(d *Docopts, args docopt.Opts) {
+            d.Arrays = "native"
+            d.Array_name = "{assoc}_{name}"
+            d.Print_bash_args("ARGS", args)
+        },
+        `eval "$DOCOPTS_CODE"
+        printf '%s\0' "${ARGS[--one]}" "${ARGS_opt[@]}" "${ARGS_args[@]}"`,
+    },
+    {
+        "global",
+        "bash",
+        func(d *Docopts, args docopt.Opts) { d.Print_bash_global(args) },
+        `eval "$DOCOPTS_CODE"
+        printf '%s\0' "$one" "${opt[@]}" "${args[@]}"`,
+    },
+    {
+        "global prefix",
+        "bash",
+        func(d *Docopts, args docopt.Opts) {
+            d.Global_prefix = "PFX"
+            d.Print_bash_global(args)
+        },
+        `eval "$DOCOPTS_CODE"
+        printf '%s\0' "$PFX_one" "${PFX_opt[@]}" "${PFX_args[@]}"`,
+    },
+    {
+        "function",
+        "bash",
+        func(d *Docopts, args docopt.Opts) {
+            d.Exit_function = true
+            d.Print_bash_global(args)
+        },
+        `f() { eval "$DOCOPTS_CODE" ; printf '%s\0' "$one" "${opt[@]}" "${args[@]}" ; }
+        f
+        [[ -z ${one+set} ]] || echo "one is not local"`,
+    },
+    {
+        // the values are read back by a child process, from the environment
+        "export",
+        "bash",
+        func(d *Docopts, args docopt.Opts) {
+            d.Export = true
+            d.Print_bash_global(args)
+        },
+        `eval "$DOCOPTS_CODE"
+        exec "$BASH" --norc --noprofile -c '` + Shellquote(Round_trip_posix_dump) + `'`,
+    },
+    {
+        "posix",
+        "posix",
+        func(d *Docopts, args docopt.Opts) { d.Print_posix_global(args) },
+        `eval "$DOCOPTS_CODE"
+        ` + Round_trip_posix_dump,
+    },
+    {
+        "posix function",
+        "posix",
+        func(d *Docopts, args docopt.Opts) {
+            d.Exit_function = true
+            d.Print_posix_global(args)
+        },
+        `f() {
+            eval "$DOCOPTS_CODE"
+            ` + Round_trip_posix_dump + `
+        }
+        f
+        [ -z "${one+set}" ] || echo "one is not local"`,
+    },
+    {
+        "posix set positional",
+        "posix",
+        func(d *Docopts, args docopt.Opts) {
+            d.Set_positional = "<args>"
+            d.Print_posix_global(args)
+        },
+        `eval "$DOCOPTS_CODE"
+        printf '%s\0' "$one"
+        i=0
+        while [ $i -lt $opt_COUNT ] ; do eval "printf '%s\0' \"\$opt_$i\"" ; i=$((i + 1)) ; done
+        for a in "$@" ; do printf '%s\0' "$a" ; done
+        [ -z "${args_COUNT+set}" ] || echo "args is not positional"`,
+    },
+    {
+        "zsh assoc",
+        "zsh",
+        func(d *Docopts, args docopt.Opts) { d.Print_zsh_args("ARGS", args) },
+        `eval "$DOCOPTS_CODE"
+        printf '%s\0' "${ARGS[--one]}"
+        for k in --opt '<args>' ; do
+            for (( i = 1; i <= ${ARGS[$k,#]}; i++ )) ; do printf '%s\0' "${ARGS[$k,$i]}" ; done
+        done`,
+    },
+    {
+        "zsh global",
+        "zsh",
+        func(d *Docopts, args docopt.Opts) { d.Print_zsh_global(args) },
+        `eval "$DOCOPTS_CODE"
+        printf '%s\0' "$one" "${opt[@]}" "${args[@]}"`,
+    },
+    {
+        "zsh function",
+        "zsh",
+        func(d *Docopts, args docopt.Opts) {
+            d.Exit_function = true
+            d.Print_zsh_global(args)
+        },
+        `f() { eval "$DOCOPTS_CODE" ; printf '%s\0' "$one" "${opt[@]}" "${args[@]}" ; }
+        f
+        [[ -z ${one+set} ]] || echo "one is not local"`,
+    },
+    {
+        "fish",
+        "fish",
+        func(d *Docopts, args docopt.Opts) { d.Print_fish_global(args) },
+        `eval $DOCOPTS_CODE
+        string join0 -- $one $opt $args`,
+    },
+    {
+        "fish function",
+        "fish",
+        func(d *Docopts, args docopt.Opts) {
+            d.Exit_function = true
+            d.Print_fish_global(args)
+        },
+        `function f
+            eval $DOCOPTS_CODE
+            string join0 -- $one $opt $args
+        end
+        f
+        set -q one ; and echo "one is not local"`,
+    },
+    {
+        "json",
+        "json",
+        func(d *Docopts, args docopt.Opts) { d.Print_json(args) },
+        "",
+    },
+}
+
+// The command running a script in the shell of a mode, skips the test if the
+// shell is not found. posix is dash, or sh if there is no dash.
+func round_trip_shell(t testing.TB, shell string) []string {
+    if shell == "bash" {
+        return []string{round_trip_bash(t), "--norc", "--noprofile", "-c"}
+    }
+    names := map[string][]string{"posix": {"dash", "sh"}, "zsh": {"zsh"}, "fish": {"fish"}}[shell]
+    for _, name := range names {
+        if path, err := exec.LookPath(name); err == nil {
+            return map[string][]string{
+                "posix": {path, "-c"},
+                "zsh": {path, "-f", "-c"},
+                "fish": {path, "--no-config", "-c"},
+            }[shell]
+        }
+    }
+    t.Skipf("%s not found", strings.Join(names, " or "))
+    return nil
+}
+
+// The values of the --json output, in the order of the dump scripts.
+func round_trip_json(code string) (string, error) {
+    var parsed struct {
+        One string `json:"--one"`
+        Opt []string `json:"--opt"`
+        Args []string `json:"<args>"`
+    }
+    if err := json.Unmarshal([]byte(code), &parsed); err != nil {
+        return "", err
+    }
+    values := append(append([]string{parsed.One}, parsed.Opt...), parsed.Args...)
+    return strings.Join(values, "\x00") + "\x00", nil
+}
+
+// Quoting styles exercised by the harness, see: --quoting
+var Round_trip_quotings = []string{"single", "ansi-c", "auto"}
+
+// $'...' strings are only supported by bash and zsh
+func (mode Round_trip_mode) quotings() []string {
+    if mode.Shell == "bash" || mode.Shell == "zsh" {
+        return Round_trip_quotings
+    }
+    return []string{"single"}
+}
+
+// Parse values through docopt and each output mode, eval the output in the
+// shell of the mode and compare the dumped variables byte for byte. Values
+// containing NUL can't be given in argv and are skipped. With json, values
+// which are not valid UTF-8 must be refused. See also: round_trip_fail()
+func round_trip(t *testing.T, values []string) {
+    for _, v := range values {
+        if strings.IndexByte(v, 0) != -1 {
+            t.Skip("NUL byte can't be given in argv")
+        }
+    }
+
+    argv := []string{}
+    expect := []string{values[0]}
+    argv = append(argv, "--one=" + values[0])
+    for _, v := range values[1:] {
+        argv = append(argv, "--opt=" + v)
+        expect = append(expect, v)
+    }
+    for _, v := range values {
+        argv = append(argv, "x" + v)
+        expect = append(expect, "x" + v)
+    }
+
+    parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler, SkipHelpFlags: true}
+    args, err := parser.ParseArgs(Round_trip_usage, argv, "")
+    if err != nil {
+        t.Skipf("docopt can't parse %q: %v", argv, err)
+    }
+
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    want := strings.Join(expect, "\x00") + "\x00"
+    for _, mode := range Round_trip_modes {
+        t.Run(mode.Name, func(t *testing.T) {
+            var shell []string
+            if mode.Shell != "json" {
+                shell = round_trip_shell(t, mode.Shell)
+            } else if !utf8.ValidString(strings.Join(argv, "")) {
+                // JSON strings are UTF-8, docopts fails instead of replacing the bytes
+                d := &Docopts{Mangle_key: true}
+                if err := d.Json_check_utf8(args); err == nil {
+                    t.Errorf("Json_check_utf8 should refuse %q", argv)
+                }
+                return
+            }
+            for _, quoting := range mode.quotings() {
+                d := &Docopts{
+                    Mangle_key: true,
+                    Output_declare: true,
+                    Reserved: "error",
+                    Quoting: quoting,
+                    Shell: mode.Shell,
+                }
+                out.(*bytes.Buffer).Reset()
+                mode.Print(d, args)
+                code := out.(*bytes.Buffer).String()
+
+                var res string
+                if mode.Shell == "json" {
+                    res, err = round_trip_json(code)
+                    if err != nil {
+                        t.Errorf("%s: invalid JSON: %v\ncode:\n%s", quoting, err, code)
+                        continue
+                    }
+                } else {
+                    cmd := exec.Command(shell[0], append(shell[1:], mode.Dump)...)
+                    cmd.Env = []string{"LC_ALL=C", "DOCOPTS_CODE=" + code}
+                    var stderr bytes.Buffer
+                    cmd.Stderr = &stderr
+                    output, err := cmd.Output()
+                    if err != nil || stderr.Len() > 0 {
+                        t.Errorf("%s: %s failed: %v %s\ncode:\n%s", quoting, shell[0], err, stderr.String(), code)
+                        continue
+                    }
+                    res = string(output)
+                }
+
+                if res != want {
+                    t.Errorf("%s: round trip\ngot:  %q\nwant: %q\ncode:\n%s", quoting, res, want, code)
+                }
+            }
+        })
+    }
+
+    round_trip_fail(t, values)
+}
+
+// Shells evaluating the output of Bash_fail_source()
+var Round_trip_fail_shells = []string{"bash", "posix", "zsh", "fish"}
+
+// The help and the usage error built from values are output by the code of
+// Bash_fail_source() evaled in each shell: the message must be output as is,
+// on stdout for the help and on stderr for the error, and the shell must stop
+// with the exit code.
+func round_trip_fail(t *testing.T, values []string) {
+    help := "Usage: prog " + strings.Join(values, " ")
+    branches := []struct {
+        name string
+        message string
+        to_stderr bool
+        exit_code int
+    }{
+        {"help", help, false, 0},
+        {"error", Error_message(errors.New(values[0]), help), true, Exit_usage},
+    }
+
+    for _, shell_name := range Round_trip_fail_shells {
+        t.Run("fail " + shell_name, func(t *testing.T) {
+            shell := round_trip_shell(t, shell_name)
+            d := &Docopts{Shell: shell_name}
+            for _, branch := range branches {
+                code := d.Bash_fail_source(branch.message, branch.to_stderr, branch.exit_code)
+                cmd := exec.Command(shell[0], append(shell[1:], `eval "$DOCOPTS_CODE" ; echo not reached`)...)
+                cmd.Env = []string{"LC_ALL=C", "DOCOPTS_CODE=" + code}
+                var stdout, stderr bytes.Buffer
+                cmd.Stdout = &stdout
+                cmd.Stderr = &stderr
+                status := 0
+                if err := cmd.Run(); err != nil {
+                    exit_err, ok := err.(*exec.ExitError)
+                    if !ok {
+                        t.Errorf("%s: %s failed: %v", branch.name, shell[0], err)
+                        continue
+                    }
+                    status = exit_err.ExitCode()
+                }
+
+                res, other := stdout.String(), stderr.String()
+                if branch.to_stderr {
+                    res, other = other, res
+                }
+                if res != branch.message + "\n" || other != "" || status != branch.exit_code {
+                    t.Errorf("%s: round trip\ngot:  %q %q exit %d\nwant: %q exit %d\ncode:\n%s",
+                        branch.name, res, other, status, branch.message + "\n", branch.exit_code, code)
+                }
+            }
+        })
+    }
+}
+
+// bash used by the harness, $DOCOPTS_TEST_BASH or bash in $PATH
+func round_trip_bash(t testing.TB) string {
+    if bash := os.Getenv("DOCOPTS_TEST_BASH"); bash != "" {
+        return bash
+    }
+    bash, err := exec.LookPath("bash")
+    if err != nil {
+        t.Skip("bash not found")
+    }
+    return bash
+}
+
+// also the seed corpus of FuzzRound_trip
+var Round_trip_values = [][]string{
+    {"plain", "a b", "c"},
+    {"", "", ""},
+    {"it's", "'", "''\\'"},
+    {"\\", "\\\\n", "a\\"},
+    {"$(touch /tmp/pwned)", "`id`", "${HOME} $PATH"},
+    {"a\nb", "\r\n", "\n"},
+    {"\xff\xfe", "\xc3", "\xe2\x80\xae"},
+    {"\x1b[31mred", "\x01\x7f", "\t\v\f"},
+    {"*", "?[a]", "~ !x !! {a,b}"},
+    {"=", "--opt=x", "-"},
+}
+
+func TestRound_trip(t *testing.T) {
+    for i, values := range Round_trip_values {
+        t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+            round_trip(t, values)
+        })
+    }
+}
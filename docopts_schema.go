@@ -0,0 +1,168 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_schema.go: JSON Schema of the object output by docopts --json, see:
+// docopts schema
+//
+package main
+
+import (
+    "strings"
+)
+
+const Schema_dialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Properties of a schema, in order of declaration of the keys: a Go map would
+// sort them.
+type Schema_properties []schema_member
+
+type schema_member struct {
+    Name string
+    Value interface{}
+}
+
+func (p Schema_properties) MarshalJSON() ([]byte, error) {
+    members := make([]string, len(p))
+    for i, m := range p {
+        members[i] = To_json(m.Name) + ":" + To_json(m.Value)
+    }
+    return []byte("{" + strings.Join(members, ",") + "}"), nil
+}
+
+type Schema_items struct {
+    Type string `json:"type"`
+}
+
+// A key of the parsed arguments. Type is a string, or a list for a value which
+// can be null.
+type Schema_property struct {
+    Type interface{} `json:"type"`
+    Items *Schema_items `json:"items,omitempty"`
+    Minimum *int `json:"minimum,omitempty"`
+    Default interface{} `json:"default"`
+    Description string `json:"description,omitempty"`
+}
+
+type Schema_object struct {
+    Properties Schema_properties `json:"properties"`
+    Required []string `json:"required,omitempty"`
+}
+
+// If a command is given, the commands exclusive with it keep their absent value.
+type Schema_condition struct {
+    If Schema_object `json:"if"`
+    Then Schema_object `json:"then"`
+}
+
+type Schema struct {
+    Schema string `json:"$schema"`
+    Title string `json:"title"`
+    Description string `json:"description"`
+    Type string `json:"type"`
+    Properties Schema_properties `json:"properties"`
+    Required []string `json:"required"`
+    Additional_properties bool `json:"additionalProperties"`
+    All_of []Schema_condition `json:"allOf,omitempty"`
+}
+
+// The schema of the value of the leaf p, the same as docopt.ParseArgs() gives:
+// a boolean or a counter for flags and commands, a list of strings for
+// repeatable arguments and options, a string or null otherwise.
+func New_schema_property(p *Pattern) *Schema_property {
+    property := &Schema_property{Default: p.Value}
+    switch p.Value.(type) {
+    case bool:
+        property.Type = "boolean"
+    case int:
+        property.Type = "integer"
+        minimum := 0
+        property.Minimum = &minimum
+    case []string:
+        property.Type = "array"
+        property.Items = &Schema_items{"string"}
+    case string:
+        property.Type = "string"
+    default:
+        property.Type = []string{"string", "null"}
+    }
+    if p.Type == Pattern_option {
+        property.Description = strings.Join(strings.Fields(p.Description), " ")
+    }
+    return property
+}
+
+// Commands which never appear together in an either case of the usage, by
+// command name, in order of declaration.
+func Exclusive_commands(u *Usage_model) map[string][]*Pattern {
+    commands := []*Pattern{}
+    for _, leaf := range u.Leaves() {
+        if leaf.Type == Pattern_command {
+            commands = append(commands, leaf)
+        }
+    }
+    together := map[[2]string]bool{}
+    for _, either_case := range u.Pattern.transform() {
+        for _, a := range either_case {
+            for _, b := range either_case {
+                together[[2]string{a.Name, b.Name}] = true
+            }
+        }
+    }
+    exclusive := map[string][]*Pattern{}
+    for _, a := range commands {
+        for _, b := range commands {
+            if a != b && !together[[2]string{a.Name, b.Name}] {
+                exclusive[a.Name] = append(exclusive[a.Name], b)
+            }
+        }
+    }
+    return exclusive
+}
+
+// Build the Schema of the parsed arguments of the usage model u.
+func New_schema(u *Usage_model) *Schema {
+    schema := &Schema{
+        Schema: Schema_dialect,
+        Title: u.Prog,
+        Description: "Arguments of " + u.Prog + " parsed by docopts --json",
+        Type: "object",
+        Properties: Schema_properties{},
+        Required: []string{},
+        Additional_properties: false,
+    }
+    leaves := u.Leaves()
+    for _, leaf := range leaves {
+        schema.Properties = append(schema.Properties, schema_member{leaf.Name, New_schema_property(leaf)})
+        schema.Required = append(schema.Required, leaf.Name)
+    }
+
+    exclusive := Exclusive_commands(u)
+    for _, leaf := range leaves {
+        others := exclusive[leaf.Name]
+        if leaf.Type != Pattern_command || len(others) == 0 {
+            continue
+        }
+        given := map[string]interface{}{"const": true}
+        if _, counted := leaf.Value.(int); counted {
+            given = map[string]interface{}{"minimum": 1}
+        }
+        condition := Schema_condition{
+            If: Schema_object{Schema_properties{{leaf.Name, given}}, []string{leaf.Name}},
+            Then: Schema_object{Properties: Schema_properties{}},
+        }
+        for _, o := range others {
+            condition.Then.Properties = append(condition.Then.Properties,
+                schema_member{o.Name, map[string]interface{}{"enum": []interface{}{o.Value}}})
+        }
+        schema.All_of = append(schema.All_of, condition)
+    }
+    return schema
+}
+
+// The Schema of the help message doc as indented JSON.
+func Schema_json(doc string) (string, error) {
+    u, err := Parse_usage(doc)
+    if err != nil {
+        return "", err
+    }
+    return To_json_indent(New_schema(u))
+}
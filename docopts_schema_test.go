@@ -0,0 +1,96 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_schema.go
+//
+package main
+
+import (
+    "testing"
+    "encoding/json"
+    "reflect"
+    "strings"
+)
+
+func TestNew_schema_property(t *testing.T) {
+    tables := []struct {
+        value interface{}
+        expect string
+    }{
+        {false, `{"type":"boolean","default":false}`},
+        {0, `{"type":"integer","minimum":0,"default":0}`},
+        {[]string{}, `{"type":"array","items":{"type":"string"},"default":[]}`},
+        {"10", `{"type":"string","default":"10"}`},
+        {nil, `{"type":["string","null"],"default":null}`},
+    }
+    for _, e := range tables {
+        got := To_json(New_schema_property(&Pattern{Type: Pattern_argument, Value: e.value}))
+        if got != e.expect {
+            t.Errorf("New_schema_property(%v), got: %s, want: %s", e.value, got, e.expect)
+        }
+    }
+    o := &Pattern{Type: Pattern_option, Value: false, Description: "Be\n      verbose."}
+    if got := New_schema_property(o).Description; got != "Be verbose." {
+        t.Errorf("New_schema_property description, got: %q", got)
+    }
+}
+
+func TestExclusive_commands(t *testing.T) {
+    u, err := Parse_usage("Usage:\n  p ship (new|move) <x>\n  p mine [set|remove]\n  p go go")
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    names := map[string][]string{}
+    for name, patterns := range Exclusive_commands(u) {
+        for _, p := range patterns {
+            names[name] = append(names[name], p.Name)
+        }
+    }
+    expect := map[string][]string{
+        "ship": {"mine", "set", "remove", "go"},
+        "new": {"move", "mine", "set", "remove", "go"},
+        "move": {"new", "mine", "set", "remove", "go"},
+        "mine": {"ship", "new", "move", "go"},
+        "set": {"ship", "new", "move", "remove", "go"},
+        "remove": {"ship", "new", "move", "set", "go"},
+        "go": {"ship", "new", "move", "mine", "set", "remove"},
+    }
+    if !reflect.DeepEqual(names, expect) {
+        t.Errorf("Exclusive_commands, got: %v, want: %v", names, expect)
+    }
+}
+
+func TestSchema_json(t *testing.T) {
+    got, err := Schema_json("Usage: p (go|stop) [-v...] [--out=F] <f>...\n\nOptions:\n  --out=F  Out [default: x].")
+    if err != nil {
+        t.Fatalf("Schema_json, error: %v", err)
+    }
+    var schema map[string]interface{}
+    if err := json.Unmarshal([]byte(got), &schema); err != nil {
+        t.Fatalf("Schema_json, invalid JSON: %v\n%s", err, got)
+    }
+    if schema["$schema"] != Schema_dialect || schema["title"] != "p" || schema["additionalProperties"] != false {
+        t.Errorf("Schema_json header, got:\n%s", got)
+    }
+    expect := []interface{}{"go", "stop", "-v", "--out", "<f>"}
+    if !reflect.DeepEqual(schema["required"], expect) {
+        t.Errorf("Schema_json required, got: %v, want: %v", schema["required"], expect)
+    }
+    // properties are in order of declaration
+    if i, j := strings.Index(got, `"-v": {`), strings.Index(got, `"--out": {`); i < 0 || j < i {
+        t.Errorf("Schema_json properties order, got:\n%s", got)
+    }
+
+    condition := To_json(schema["allOf"].([]interface{})[0])
+    expect_condition := `{"if":{"properties":{"go":{"const":true}},"required":["go"]},"then":{"properties":{"stop":{"enum":[false]}}}}`
+    if condition != expect_condition {
+        t.Errorf("Schema_json allOf, got: %s, want: %s", condition, expect_condition)
+    }
+
+    got, _ = Schema_json("Usage: p <a>")
+    if Match(`allOf`, got) {
+        t.Errorf("Schema_json without commands, got:\n%s", got)
+    }
+    if _, err := Schema_json("Usage: p ("); err == nil {
+        t.Errorf("Schema_json of a bad usage, no error")
+    }
+}
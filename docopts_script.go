@@ -0,0 +1,144 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_script.go: read the usage and version from the comments of a
+// script, see: --from-script
+//
+package main
+
+import (
+    "fmt"
+    "io/ioutil"
+    "regexp"
+    "strings"
+)
+
+// default comment marker: usage and version are comment blocks
+const Script_marker_default = "#"
+
+// first line of the version block with the default marker
+const Script_version_block = "----"
+
+// Read the usage and version of the script at path, see: Parse_script().
+func Read_script(path string, marker string, version_marker string) (string, string, error) {
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        return "", "", fmt.Errorf("--from-script: %v", err)
+    }
+    usage, version := Parse_script(string(content), marker, version_marker)
+    return usage, version, nil
+}
+
+// Read the description of the script at path, see: Parse_script_description().
+func Read_script_description(path string, marker string) (string, error) {
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        return "", fmt.Errorf("--from-script: %v", err)
+    }
+    return Parse_script_description(string(content), marker), nil
+}
+
+// Error to report when no usage was found in the script at path.
+func Script_usage_error(path string, marker string) error {
+    if marker == Script_marker_default {
+        return fmt.Errorf("--from-script: no usage found in %s: expected a comment block starting with '# Usage:'", path)
+    }
+    return fmt.Errorf("--from-script: no usage found in %s: expected lines starting with '%s'", path, marker)
+}
+
+// Extract the usage and version from the comments of a script.
+// With the default marker #, the usage is the block of comment lines starting
+// at '# Usage:', case insensitive, up to the first line which is not a
+// comment, and the version is the block following a '# ----' line.
+// With any other marker, like ##?, the usage is made of all the lines starting
+// with marker, the version of all the lines starting with version_marker, like #?.
+// The marker and one following space or tab are removed.
+func Parse_script(content string, marker string, version_marker string) (string, string) {
+    lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+
+    if marker != Script_marker_default {
+        usage := script_marked_lines(lines, marker)
+        version := ""
+        if version_marker != "" {
+            version = script_marked_lines(lines, version_marker)
+        }
+        return usage, version
+    }
+
+    usage_start := regexp.MustCompile(`(?i)^usage:`)
+    var usage, version []string
+    var block *[]string
+    for _, line := range lines {
+        text, is_comment := script_uncomment(line, marker)
+        if !is_comment || strings.HasPrefix(line, "#!") {
+            block = nil
+            continue
+        }
+        if strings.HasPrefix(strings.TrimSpace(text), Script_version_block) {
+            block = nil
+            if version == nil {
+                block = &version
+                version = []string{}
+            }
+            continue
+        }
+        if block == nil && usage == nil && usage_start.MatchString(strings.TrimSpace(text)) {
+            block = &usage
+        }
+        if block != nil {
+            *block = append(*block, text)
+        }
+    }
+    return script_join(usage), script_join(version)
+}
+
+// The description of a script: the comment lines preceding '# Usage:' in the
+// same comment block, empty with another marker than the default one.
+func Parse_script_description(content string, marker string) string {
+    if marker != Script_marker_default {
+        return ""
+    }
+    usage_start := regexp.MustCompile(`(?i)^usage:`)
+    block := []string{}
+    for _, line := range strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n") {
+        text, is_comment := script_uncomment(line, marker)
+        if !is_comment || strings.HasPrefix(line, "#!") ||
+            strings.HasPrefix(strings.TrimSpace(text), Script_version_block) {
+            block = []string{}
+            continue
+        }
+        if usage_start.MatchString(strings.TrimSpace(text)) {
+            return strings.TrimSpace(script_join(block))
+        }
+        block = append(block, text)
+    }
+    return ""
+}
+
+// Remove marker and one following space or tab from line, false if line is not
+// commented by marker.
+func script_uncomment(line string, marker string) (string, bool) {
+    if !strings.HasPrefix(line, marker) {
+        return "", false
+    }
+    text := line[len(marker):]
+    if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+        text = text[1:]
+    }
+    return text, true
+}
+
+// all the uncommented lines starting with marker
+func script_marked_lines(lines []string, marker string) string {
+    marked := []string{}
+    for _, line := range lines {
+        if text, ok := script_uncomment(line, marker); ok {
+            marked = append(marked, text)
+        }
+    }
+    return script_join(marked)
+}
+
+// join lines, trailing empty lines are removed
+func script_join(lines []string) string {
+    return strings.TrimRight(strings.Join(lines, "\n"), " \t\n")
+}
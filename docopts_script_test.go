@@ -0,0 +1,133 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_script.go
+//
+package main
+
+import (
+    "testing"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+)
+
+func TestParse_script(t *testing.T) {
+    script := `#!/usr/bin/env bash
+# Naval Fate.
+#
+# Usage:
+#   naval_fate.sh ship <name> move [--speed=<kn>]
+#	naval_fate.sh -h | --help
+#
+# Options:
+#   --speed=<kn>  Speed in knots [default: 10].
+# ----
+# Naval Fate 2.0
+# Copyright (C) 2013
+# Usage: not a second usage
+
+echo "code"
+`
+    usage, version := Parse_script(script, "#", "")
+    expect := "Usage:\n" +
+        "  naval_fate.sh ship <name> move [--speed=<kn>]\n" +
+        "naval_fate.sh -h | --help\n" +
+        "\n" +
+        "Options:\n" +
+        "  --speed=<kn>  Speed in knots [default: 10]."
+    if usage != expect {
+        t.Errorf("Parse_script usage\ngot: %q\nwant: %q", usage, expect)
+    }
+    expect = "Naval Fate 2.0\nCopyright (C) 2013\nUsage: not a second usage"
+    if version != expect {
+        t.Errorf("Parse_script version\ngot: %q\nwant: %q", version, expect)
+    }
+
+    // block ends at the first line which is not a comment, usage: is case insensitive
+    usage, version = Parse_script("#!/bin/sh\n#usage: p FILE\n\n# Options:\n", "#", "")
+    if usage != "usage: p FILE" || version != "" {
+        t.Errorf("Parse_script short block, got: %q %q", usage, version)
+    }
+
+    usage, version = Parse_script("#!/bin/sh\n# no usage here\necho\n", "#", "")
+    if usage != "" {
+        t.Errorf("Parse_script without usage, got: %q", usage)
+    }
+}
+
+func TestParse_script_description(t *testing.T) {
+    tables := map[string]string{
+        "#!/bin/bash\n# Naval Fate.\n#   the game\n#\n# Usage: p\n": "Naval Fate.\n  the game",
+        "#!/bin/bash\n# License\n\n# Usage: p\n": "",
+        "# ----\n# 1.0\n# ----\n# Usage: p\n": "",
+        "# Usage: p\n": "",
+        "# no usage\n": "",
+    }
+    for script, expect := range tables {
+        if got := Parse_script_description(script, "#"); got != expect {
+            t.Errorf("Parse_script_description(%q), got: %q, want: %q", script, got, expect)
+        }
+    }
+    if got := Parse_script_description("##? Usage: p\n", "##?"); got != "" {
+        t.Errorf("Parse_script_description with a marker, got: %q", got)
+    }
+}
+
+func TestParse_script_marker(t *testing.T) {
+    script := `#!/usr/bin/env bash
+#? rock 0.1.0
+#? Copyright (C) 200X Thomas Light
+
+##? Usage: rock [options] <argv>...
+##?
+##?       --help     Show help options.
+# a regular comment
+##?       --version  Print program version.
+`
+    usage, version := Parse_script(script, "##?", "#?")
+    expect := "Usage: rock [options] <argv>...\n\n      --help     Show help options.\n      --version  Print program version."
+    if usage != expect {
+        t.Errorf("Parse_script ##? usage\ngot: %q\nwant: %q", usage, expect)
+    }
+    expect = "rock 0.1.0\nCopyright (C) 200X Thomas Light"
+    if version != expect {
+        t.Errorf("Parse_script #? version\ngot: %q\nwant: %q", version, expect)
+    }
+
+    if _, version = Parse_script(script, "##?", ""); version != "" {
+        t.Errorf("Parse_script without version marker, got: %q", version)
+    }
+}
+
+func TestRead_script(t *testing.T) {
+    dir, err := ioutil.TempDir("", "docopts")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    path := filepath.Join(dir, "script.sh")
+    ioutil.WriteFile(path, []byte("# Usage: p FILE\r\n"), 0644)
+    usage, _, err := Read_script(path, "#", "")
+    if err != nil || usage != "Usage: p FILE" {
+        t.Errorf("Read_script, got: %q %v", usage, err)
+    }
+
+    if _, _, err := Read_script(filepath.Join(dir, "missing"), "#", ""); err == nil {
+        t.Errorf("Read_script of a missing file should fail")
+    }
+
+    ioutil.WriteFile(path, []byte("#!/bin/sh\n# Naval Fate.\n#\n# Usage: p FILE\n"), 0644)
+    description, err := Read_script_description(path, "#")
+    if err != nil || description != "Naval Fate." {
+        t.Errorf("Read_script_description, got: %q %v", description, err)
+    }
+    if _, err := Read_script_description(filepath.Join(dir, "missing"), "#"); err == nil {
+        t.Errorf("Read_script_description of a missing file should fail")
+    }
+
+    want := "--from-script: no usage found in x.sh: expected lines starting with '##?'"
+    if err := Script_usage_error("x.sh", "##?"); err.Error() != want {
+        t.Errorf("Script_usage_error, got: %v, want: %v", err, want)
+    }
+}
@@ -153,6 +153,7 @@ func TestTo_bash(t *testing.T) {
         {nil, ""},
         {"", "''"},
         {[]string{"pipo", "molo"}, "('pipo' 'molo')"},
+        {[]string{}, "()"},
         {true, "true"},
     }
 
@@ -324,3 +325,397 @@ func TestName_mangle(t *testing.T) {
         }
     }
 }
+
+func TestTo_json(t *testing.T) {
+    tables := []struct {
+        input interface{}
+        expect string
+    }{
+        {"pipo", `"pipo"`},
+        {123, "123"},
+        {nil, "null"},
+        {true, "true"},
+        {[]string{"pipo", "molo"}, `["pipo","molo"]`},
+        {[]string{}, `[]`},
+        {map[string]interface{}{"<file>": "a&b", "--v": 2}, `{"--v":2,"<file>":"a&b"}`},
+    }
+
+    for _, table := range tables {
+        res := To_json(table.input)
+        if res != table.expect {
+           t.Errorf("To_json for '%v', got: %v, want: %v.", table.input, res, table.expect)
+        }
+    }
+}
+
+func TestPrint_json(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d := &Docopts{
+        Global_prefix: "",
+        Mangle_key: true,
+    }
+
+    tables, _ := test_json_loader.Load_json("./common_input_test.json")
+    for _, table := range tables {
+        d.Print_json(table.Input)
+        res := out.(*bytes.Buffer).String()
+        expect := table.Expect_json + "\n"
+        if res != expect {
+           t.Errorf("Print_json for '%v'\ngot: '%v'\nwant: '%v'\n", table.Input, res, expect)
+        }
+        out.(*bytes.Buffer).Reset()
+    }
+}
+
+func TestShell_global_names(t *testing.T) {
+    args := map[string]interface{}{"--out": "x", "FILE": []string{"a"}}
+    d := &Docopts{Mangle_key: true}
+    expect := map[string]string{"--out": "out", "FILE": "FILE"}
+    if res := d.Shell_global_names(args); !reflect.DeepEqual(res, expect) {
+        t.Errorf("Shell_global_names\ngot: %v\nwant: %v", res, expect)
+    }
+
+    // exported arrays also name their count and values
+    d.Export = true
+    expect = map[string]string{"--out": "out", "FILE": "FILE", "FILE,#": "FILE_COUNT", "FILE,0": "FILE_0"}
+    if res := d.Shell_global_names(args); !reflect.DeepEqual(res, expect) {
+        t.Errorf("Shell_global_names with Export\ngot: %v\nwant: %v", res, expect)
+    }
+
+    // verbatim keys are never exported as arrays
+    d.Mangle_key = false
+    expect = map[string]string{"--out": "--out", "FILE": "FILE"}
+    if res := d.Shell_global_names(args); !reflect.DeepEqual(res, expect) {
+        t.Errorf("Shell_global_names with --no-mangle\ngot: %v\nwant: %v", res, expect)
+    }
+}
+
+func TestJson_check_utf8(t *testing.T) {
+    d := &Docopts{
+        Key_order: []string{"--out", "FILE"},
+    }
+    tables := []struct {
+        input map[string]interface{}
+        expect string
+    }{
+        {map[string]interface{}{"--out": "é", "FILE": []string{"a", "b"}, "-v": 2, "--in": nil}, ""},
+        {map[string]interface{}{"--out": "x\xff", "FILE": []string{"a"}}, `--json: value of '--out' is not valid UTF-8: "x\xff"`},
+        {map[string]interface{}{"--out": "x\xff", "FILE": []string{"a", "\xe9"}}, `--json: value of '--out' is not valid UTF-8: "x\xff"`},
+        {map[string]interface{}{"--out": nil, "FILE": []string{"a", "\xe9"}}, `--json: value of 'FILE' is not valid UTF-8: "\xe9"`},
+    }
+
+    for _, table := range tables {
+        err := d.Json_check_utf8(table.input)
+        res := ""
+        if err != nil {
+            res = err.Error()
+        }
+        if res != table.expect {
+           t.Errorf("Json_check_utf8 for '%v'\ngot: '%v'\nwant: '%v'\n", table.input, res, table.expect)
+        }
+    }
+}
+
+func TestBash_fail_source(t *testing.T) {
+    d := &Docopts{}
+    res := d.Bash_fail_source("Usage: it's", false, 0)
+    expect := "echo 'Usage: it'\\''s'\nexit 0\n"
+    if res != expect {
+        t.Errorf("Bash_fail_source\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+
+    res = d.Bash_fail_source(Error_message(errors.New("oops"), "Usage: p"), true, Exit_usage)
+    expect = "echo 'error: oops\nUsage: p' >&2\nexit 64\n"
+    if res != expect {
+        t.Errorf("Bash_fail_source\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
+
+func TestExit_function(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d := &Docopts{
+        Global_prefix: "",
+        Mangle_key: true,
+        Output_declare: true,
+        Exit_function: true,
+    }
+
+    if res := d.Get_exit_code(64); res != "return 64" {
+        t.Errorf("Get_exit_code with Exit_function, got: %v, want: return 64", res)
+    }
+
+    d.Print_bash_args("args", map[string]interface{}{"--counter": 2})
+    res := out.(*bytes.Buffer).String()
+    expect := "local -A args\nargs['--counter']=2\n"
+    if res != expect {
+        t.Errorf("Print_bash_args with Exit_function\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+    out.(*bytes.Buffer).Reset()
+
+    d.Print_bash_global(map[string]interface{}{"FILE": []string{"pipo", "molo"}})
+    res = out.(*bytes.Buffer).String()
+    expect = "local FILE=('pipo' 'molo')\n"
+    if res != expect {
+        t.Errorf("Print_bash_global with Exit_function\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
+
+func TestMangle_keys(t *testing.T) {
+    d := &Docopts{
+        Global_prefix: "",
+        Mangle_key: true,
+    }
+
+    names, err := d.Mangle_keys([]string{"--dry-run", "<file>", "-v", "FILE"})
+    expect := map[string]string{"--dry-run": "dry_run", "<file>": "file", "-v": "v", "FILE": "FILE"}
+    if err != nil || !reflect.DeepEqual(names, expect) {
+        t.Errorf("Mangle_keys\ngot: '%v' %v\nwant: '%v'\n", names, err, expect)
+    }
+
+    keys := []string{"--dry-run", "<dry_run>", "-v", "v", "--other"}
+    _, err = d.Mangle_keys(keys)
+    expect_err := "mangled names collision: '--dry-run', '<dry_run>' => 'dry_run'; '-v', 'v' => 'v'"
+    if err == nil || err.Error() != expect_err {
+        t.Errorf("Mangle_keys collision\ngot: '%v'\nwant: '%v'\n", err, expect_err)
+    }
+
+    d.Mangle_suffix = true
+    names, err = d.Mangle_keys(keys)
+    expect = map[string]string{"--dry-run": "dry_run_opt", "<dry_run>": "dry_run_arg",
+        "-v": "v_opt", "v": "v_cmd", "--other": "other"}
+    if err != nil || !reflect.DeepEqual(names, expect) {
+        t.Errorf("Mangle_keys with suffix\ngot: '%v' %v\nwant: '%v'\n", names, err, expect)
+    }
+
+    // same kind still collides
+    _, err = d.Mangle_keys([]string{"-v", "--v"})
+    if err == nil {
+        t.Errorf("Mangle_keys with suffix for '-v' '--v' must fail")
+    }
+}
+
+func TestMangle_suffix(t *testing.T) {
+    tables := []struct {
+        input string
+        expect string
+    }{
+        {"-v", "_opt"},
+        {"--dry-run", "_opt"},
+        {"<file>", "_arg"},
+        {"FILE", "_arg"},
+        {"run", "_cmd"},
+        {"File", "_cmd"},
+    }
+
+    for _, table := range tables {
+        res := Mangle_suffix(table.input)
+        if res != table.expect {
+           t.Errorf("Mangle_suffix for '%s', got: %v, want: %v.", table.input, res, table.expect)
+        }
+    }
+}
+
+func TestOrdered_keys(t *testing.T) {
+    args := map[string]interface{}{
+        "FILE": []string{"pipo"},
+        "--out": nil,
+        "-v": 2,
+        "cmd": true,
+        "--in": "x",
+    }
+
+    // no order given: alphabetical
+    d := &Docopts{}
+    expect := []string{"--in", "--out", "-v", "FILE", "cmd"}
+    if res := d.Ordered_keys(args); !reflect.DeepEqual(res, expect) {
+        t.Errorf("Ordered_keys alphabetical\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+
+    // unknown keys are ignored, missing keys are appended sorted
+    d.Key_order = []string{"cmd", "-v", "nope", "FILE"}
+    expect = []string{"cmd", "-v", "FILE", "--in", "--out"}
+    if res := d.Ordered_keys(args); !reflect.DeepEqual(res, expect) {
+        t.Errorf("Ordered_keys with Key_order\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+
+    // all output formatters follow the order
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d.Mangle_key = true
+    d.Print_bash_global(args)
+    res := out.(*bytes.Buffer).String()
+    expect_out := "cmd=true\nv=2\nFILE=('pipo')\nin='x'\nout=\n"
+    if res != expect_out {
+        t.Errorf("Print_bash_global ordered\ngot: '%v'\nwant: '%v'\n", res, expect_out)
+    }
+    out.(*bytes.Buffer).Reset()
+
+    d.Print_bash_args("args", args)
+    res = out.(*bytes.Buffer).String()
+    expect_out = "args['cmd']=true\nargs['-v']=2\nargs['FILE,0']='pipo'\nargs['FILE,#']=1\nargs['--in']='x'\nargs['--out']=\n"
+    if res != expect_out {
+        t.Errorf("Print_bash_args ordered\ngot: '%v'\nwant: '%v'\n", res, expect_out)
+    }
+    out.(*bytes.Buffer).Reset()
+
+    d.Print_json(args)
+    res = out.(*bytes.Buffer).String()
+    expect_out = `{"cmd":true,"-v":2,"FILE":["pipo"],"--in":"x","--out":null}` + "\n"
+    if res != expect_out {
+        t.Errorf("Print_json ordered\ngot: '%v'\nwant: '%v'\n", res, expect_out)
+    }
+}
+
+func TestName_mangle_reserved(t *testing.T) {
+    tables := []struct{
+        input string
+        reserved string
+        prefix string
+        expect Expected
+    }{
+        {"<path>", "", "", Expected{s: "", e: errors.New("fail")}},
+        {"--home", "error", "", Expected{s: "", e: errors.New("fail")}},
+        {"IFS", "error", "", Expected{s: "", e: errors.New("fail")}},
+        {"<PWD>", "error", "", Expected{s: "", e: errors.New("fail")}},
+        {"--random", "error", "", Expected{s: "", e: errors.New("fail")}},
+        {"--bash-source", "error", "", Expected{s: "bash_source", e: nil}},
+        {"BASH_SOURCE", "error", "", Expected{s: "", e: errors.New("fail")}},
+        {"<path>", "prefix", "", Expected{s: "_path", e: nil}},
+        {"--home", "allow", "", Expected{s: "home", e: nil}},
+        {"<path>", "error", "ARGS", Expected{s: "ARGS_path", e: nil}},
+        {"<argv>", "error", "BASH", Expected{s: "BASH_argv", e: nil}},
+        {"ARGV", "error", "BASH", Expected{s: "", e: errors.New("fail")}},
+    }
+
+    for _, table := range tables {
+        d := &Docopts{
+            Global_prefix: table.prefix,
+            Mangle_key: true,
+            Reserved: table.reserved,
+        }
+        res, err := d.Name_mangle(table.input)
+        if table.expect.e != nil && err == nil {
+           t.Errorf("Name_mangle reserved for '%v' %v\ngot: '%v'\nwant: '%v'\n", table.input, table.reserved, err, table.expect.e)
+        }
+        if res != table.expect.s {
+           t.Errorf("Name_mangle reserved for '%v' %v\ngot: '%v'\nwant: '%v'\n", table.input, table.reserved, res, table.expect.s)
+        }
+    }
+}
+
+func TestPrint_bash_native_arrays(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d := &Docopts{
+        Global_prefix: "",
+        Mangle_key: true,
+        Output_declare: true,
+        Arrays: "native",
+        Array_name: "{assoc}_{name}",
+    }
+
+    args := map[string]interface{}{
+        "--verbose": true,
+        "<file>": []string{"a b", "c"},
+        "NAME": []string{},
+    }
+    d.Print_bash_args("ARGS", args)
+    res := out.(*bytes.Buffer).String()
+    expect := "declare -A ARGS\n" +
+        "ARGS['--verbose']=true\n" +
+        "ARGS['<file>,0']='a b'\n" +
+        "ARGS['<file>,1']='c'\n" +
+        "ARGS['<file>,#']=2\n" +
+        "ARGS['NAME,#']=0\n" +
+        "declare -a ARGS_file=('a b' 'c')\n" +
+        "declare -a ARGS_NAME=()\n"
+    if res != expect {
+        t.Errorf("Print_bash_args native arrays\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
+
+func TestNative_array_names(t *testing.T) {
+    d := &Docopts{
+        Mangle_key: true,
+        Array_name: "{name}s",
+        Reserved: "error",
+    }
+
+    names, err := d.Native_array_names("ARGS", map[string]interface{}{
+        "--dry-run": false,
+        "<file>": []string{"a"},
+        "--tag": []string{"x"},
+    })
+    if err != nil {
+        t.Fatalf("Native_array_names error: %v", err)
+    }
+    expect := map[string]string{"<file>": "files", "--tag": "tags"}
+    if !reflect.DeepEqual(names, expect) {
+        t.Errorf("Native_array_names, got: %v, want: %v", names, expect)
+    }
+
+    d.Array_name = "{assoc}"
+    _, err = d.Native_array_names("ARGS", map[string]interface{}{"<file>": []string{"a"}})
+    want := "--array-name: cannot name the array of '<file>': 'ARGS'"
+    if err == nil || err.Error() != want {
+        t.Errorf("Native_array_names same as assoc, got: %v, want: %v", err, want)
+    }
+
+    d.Array_name = "x"
+    _, err = d.Native_array_names("ARGS", map[string]interface{}{"<a>": []string{}, "<b>": []string{}})
+    want = "--array-name: arrays collision: '<a>', '<b>' => 'x'"
+    if err == nil || err.Error() != want {
+        t.Errorf("Native_array_names collision, got: %v, want: %v", err, want)
+    }
+}
+
+func TestSubcommand_usage(t *testing.T) {
+    for name, line := range Subcommands {
+        // the help of docopts documents the same usage line
+        if !strings.Contains(Usage, "\n  " + line) {
+            t.Errorf("Usage must contain the usage line of %s: '%s'", name, line)
+        }
+        usage := Subcommand_usage(name)
+        if !strings.HasPrefix(usage, "Usage:\n  " + line + "\n\nOptions:\n") {
+            t.Errorf("Subcommand_usage for %s, got:\n%s", name, usage)
+        }
+        if _, err := Parse_usage(usage); err != nil {
+            t.Errorf("Subcommand_usage for %s, Parse_usage error: %v", name, err)
+        }
+    }
+}
+
+func TestSplit_subcommand_argv(t *testing.T) {
+    tables := []struct {
+        argv []string
+        own string
+        program string
+    }{
+        {[]string{"exec", "-h", "usage: p", ":", "-v", "--", "env"}, `[exec -h usage: p :]`, `[-v -- env]`},
+        {[]string{"exec", "-s", ":", "-h", "-", ":", "a"}, `[exec -s : -h - :]`, `[a]`},
+        {[]string{"exec", "-Os", ":", "--help", ":", ":", "a"}, `[exec -Os : --help : :]`, `[a]`},
+        {[]string{"complete", "--sep", ":", "--from-script=:", ":"}, `[complete --sep : --from-script=: :]`, `[]`},
+        {[]string{"complete", "--debug", ":"}, `[complete --debug :]`, `[]`},
+        {[]string{"lint", "-h", "usage: p"}, `[lint -h usage: p]`, `[]`},
+    }
+    usage := Subcommand_usage("exec")
+    for _, table := range tables {
+        own, program := Split_subcommand_argv(usage, table.argv)
+        if fmt.Sprint(own) != table.own || fmt.Sprint(program) != table.program {
+            t.Errorf("Split_subcommand_argv for %q\ngot: %v %v\nwant: %s %s", table.argv, own, program, table.own, table.program)
+        }
+    }
+}
@@ -0,0 +1,634 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_usage.go: usage model, a port of docopt's pattern parser.
+// docopt-go doesn't export its parsed patterns, this model gives access to the
+// usage structure: patterns, commands, arguments, options and their order of
+// declaration.
+//
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// Kind of a Pattern node, same as docopt's patterns.
+type Pattern_type int
+
+const (
+    // leaf
+    Pattern_argument Pattern_type = iota
+    Pattern_command
+    Pattern_option
+    // branch
+    Pattern_required
+    Pattern_optional
+    Pattern_options_shortcut
+    Pattern_one_or_more
+    Pattern_either
+)
+
+var pattern_type_names = []string{
+    "argument",
+    "command",
+    "option",
+    "required",
+    "optional",
+    "options_shortcut",
+    "one_or_more",
+    "either",
+}
+
+func (t Pattern_type) String() string {
+    return pattern_type_names[t]
+}
+
+// A node of the usage tree. Leaves are arguments, commands and options, branches
+// hold Children. Value follows docopt's rules: it is the value the leaf takes
+// when absent from argv, and becomes an int counter or a []string for repeatable
+// elements.
+type Pattern struct {
+    Type Pattern_type
+    Children []*Pattern
+
+    Name string
+    Value interface{}
+
+    // options only
+    Short string
+    Long string
+    Argcount int
+    // placeholder of the option argument, like <kn> or FILE
+    Arg string
+    // text after the option in the Options: section
+    Description string
+}
+
+// The parsed usage: the Usage: section, the program name, the options described
+// in Options: sections and the pattern tree matching argv.
+type Usage_model struct {
+    Section string
+    Prog string
+    Options []*Pattern
+    Pattern *Pattern
+}
+
+func (p *Pattern) Is_leaf() bool {
+    return p.Type <= Pattern_option
+}
+
+// A repeatable leaf counts its occurrences, or collects its values.
+func (p *Pattern) Repeatable() bool {
+    switch p.Value.(type) {
+    case int, []string:
+        return true
+    }
+    return false
+}
+
+func new_branch(t Pattern_type, children ...*Pattern) *Pattern {
+    return &Pattern{Type: t, Children: children}
+}
+
+func new_option(short, long string, argcount int, value interface{}) *Pattern {
+    name := long
+    if name == "" {
+        name = short
+    }
+    if value == false && argcount > 0 {
+        value = nil
+    }
+    return &Pattern{Type: Pattern_option, Name: name, Short: short, Long: long,
+        Argcount: argcount, Value: value}
+}
+
+// A usage parse error about the option Name, the message is the same as
+// docopt's. docopts lint uses Name to locate the error.
+type Usage_option_error struct {
+    Name string
+    Message string
+}
+
+func (e *Usage_option_error) Error() string {
+    return e.Name + " " + e.Message
+}
+
+// Parse the docopt text doc into its Usage model, following the same rules as
+// docopt.ParseArgs().
+func Parse_usage(doc string) (*Usage_model, error) {
+    sections := parse_section("usage:", doc)
+    if len(sections) == 0 {
+        return nil, fmt.Errorf(`"usage:" (case-insensitive) not found.`)
+    }
+    if len(sections) > 1 {
+        return nil, fmt.Errorf(`More than one "usage:" (case-insensitive).`)
+    }
+
+    u := &Usage_model{Section: sections[0]}
+    u.Options = parse_defaults(doc)
+
+    formal, prog, err := formal_usage(u.Section)
+    if err != nil {
+        return nil, err
+    }
+    u.Prog = prog
+
+    // options found in patterns are added to a copy of the options list
+    options := make([]*Pattern, len(u.Options))
+    copy(options, u.Options)
+    tokens := tokenize_pattern(formal)
+    result, err := parse_expr(tokens, &options)
+    if err != nil {
+        return nil, err
+    }
+    if tokens.current() != "" {
+        return nil, fmt.Errorf("unexpected ending: '%s'", strings.Join(tokens.tokens, " "))
+    }
+    u.Pattern = new_branch(Pattern_required, result...)
+
+    // [options] expands to the described options not already in the patterns
+    pattern_options := u.Pattern.Flat(Pattern_option)
+    for _, shortcut := range u.Pattern.Flat(Pattern_options_shortcut) {
+        shortcut.Children = nil
+        for _, o := range u.Options {
+            if find_pattern(pattern_options, o) == nil && find_pattern(shortcut.Children, o) == nil {
+                shortcut.Children = append(shortcut.Children, o)
+            }
+        }
+    }
+
+    u.Pattern.fix_identities(unique_patterns(u.Pattern.Flat()))
+    u.Pattern.fix_repeating_arguments()
+
+    return u, nil
+}
+
+// Leaves of the tree, in order of declaration, without duplicate.
+func (u *Usage_model) Leaves() []*Pattern {
+    return unique_patterns(u.Pattern.Flat())
+}
+
+// Keys of the parsed arguments, in order of declaration.
+func (u *Usage_model) Keys() []string {
+    leaves := u.Leaves()
+    keys := make([]string, len(leaves))
+    for i, l := range leaves {
+        keys[i] = l.Name
+    }
+    return keys
+}
+
+// The pattern of each usage line, the alternatives of a top level either.
+func (u *Usage_model) Patterns() []*Pattern {
+    lines := u.Pattern.Children
+    if len(lines) == 1 && lines[0].Type == Pattern_either {
+        return lines[0].Children
+    }
+    return lines
+}
+
+// The text of each usage line, spaces normalized, same as formal_usage().
+func (u *Usage_model) Lines() []string {
+    _, _, section := partition(u.Section, ":")
+    lines := []string{}
+    for _, word := range strings.Fields(section) {
+        if word == u.Prog {
+            lines = append(lines, word)
+        } else if len(lines) > 0 {
+            lines[len(lines)-1] += " " + word
+        }
+    }
+    return lines
+}
+
+// Flatten the tree: returns the nodes of the given types, all leaves if no type
+// is given. A branch of a given type is returned without its children.
+func (p *Pattern) Flat(types ...Pattern_type) []*Pattern {
+    for _, t := range types {
+        if p.Type == t {
+            return []*Pattern{p}
+        }
+    }
+    if p.Is_leaf() {
+        if len(types) == 0 {
+            return []*Pattern{p}
+        }
+        return []*Pattern{}
+    }
+    result := []*Pattern{}
+    for _, child := range p.Children {
+        result = append(result, child.Flat(types...)...)
+    }
+    return result
+}
+
+// leaves are the same element if they have the same type and name
+func same_pattern(a, b *Pattern) bool {
+    return a.Type == b.Type && a.Name == b.Name
+}
+
+func find_pattern(list []*Pattern, p *Pattern) *Pattern {
+    for _, e := range list {
+        if same_pattern(e, p) {
+            return e
+        }
+    }
+    return nil
+}
+
+func unique_patterns(list []*Pattern) []*Pattern {
+    result := []*Pattern{}
+    for _, p := range list {
+        if find_pattern(result, p) == nil {
+            result = append(result, p)
+        }
+    }
+    return result
+}
+
+// Make the leaves of the tree point to the same object if they are the same element.
+func (p *Pattern) fix_identities(uniq []*Pattern) {
+    for i, child := range p.Children {
+        if child.Is_leaf() {
+            p.Children[i] = find_pattern(uniq, child)
+        } else {
+            child.fix_identities(uniq)
+        }
+    }
+}
+
+// Fix elements that should accumulate/increment values: an element appearing
+// more than once in a single either case.
+func (p *Pattern) fix_repeating_arguments() {
+    for _, either_case := range p.transform() {
+        for _, e := range either_case {
+            count := 0
+            for _, other := range either_case {
+                if other == e {
+                    count++
+                }
+            }
+            if count < 2 {
+                continue
+            }
+            if e.Type == Pattern_argument || e.Type == Pattern_option && e.Argcount > 0 {
+                switch e.Value.(type) {
+                case string:
+                    e.Value = strings.Fields(e.Value.(string))
+                case []string:
+                default:
+                    e.Value = []string{}
+                }
+            }
+            if e.Type == Pattern_command || e.Type == Pattern_option && e.Argcount == 0 {
+                e.Value = 0
+            }
+        }
+    }
+}
+
+// Expand the tree into an (almost) equivalent list of either cases of leaves.
+// Example: ((-a | -b) (-c | -d)) => (-a -c | -a -d | -b -c | -b -d)
+// Quirks: [-a] => (-a), (-a...) => (-a -a)
+func (p *Pattern) transform() [][]*Pattern {
+    result := [][]*Pattern{}
+    groups := [][]*Pattern{{p}}
+    for len(groups) > 0 {
+        children := groups[0]
+        groups = groups[1:]
+        index := -1
+        for i, c := range children {
+            if !c.Is_leaf() {
+                index = i
+                break
+            }
+        }
+        if index == -1 {
+            result = append(result, children)
+            continue
+        }
+        child := children[index]
+        rest := make([]*Pattern, 0, len(children)-1)
+        rest = append(rest, children[:index]...)
+        rest = append(rest, children[index+1:]...)
+        switch child.Type {
+        case Pattern_either:
+            for _, c := range child.Children {
+                group := append([]*Pattern{c}, rest...)
+                groups = append(groups, group)
+            }
+        case Pattern_one_or_more:
+            group := append([]*Pattern{}, child.Children...)
+            group = append(group, child.Children...)
+            groups = append(groups, append(group, rest...))
+        default:
+            group := append([]*Pattern{}, child.Children...)
+            groups = append(groups, append(group, rest...))
+        }
+    }
+    return result
+}
+
+// Extract the sections starting with name, a section ends at the first non
+// indented line.
+func parse_section(name, source string) []string {
+    re := regexp.MustCompile(`(?im)^([^\n]*` + name + `[^\n]*\n?(?:[ \t].*?(?:\n|$))*)`)
+    sections := re.FindAllString(source, -1)
+    for i, s := range sections {
+        sections[i] = strings.TrimSpace(s)
+    }
+    return sections
+}
+
+// Parse all options described in the Options: sections.
+func parse_defaults(doc string) []*Pattern {
+    defaults := []*Pattern{}
+    re := regexp.MustCompile(`\n[ \t]*(-\S+?)`)
+    for _, s := range parse_section("options:", doc) {
+        _, _, s = partition(s, ":")
+        split := re.Split("\n"+s, -1)[1:]
+        match := re.FindAllStringSubmatch("\n"+s, -1)
+        for i := range split {
+            description := match[i][1] + split[i]
+            if strings.HasPrefix(description, "-") {
+                defaults = append(defaults, parse_option(description))
+            }
+        }
+    }
+    return defaults
+}
+
+// Parse a single option description: "-o FILE, --output=FILE  description".
+func parse_option(description string) *Pattern {
+    options, _, text := partition(strings.TrimSpace(description), "  ")
+    options = strings.Replace(options, ",", " ", -1)
+    options = strings.Replace(options, "=", " ", -1)
+
+    short, long, arg := "", "", ""
+    argcount := 0
+    var value interface{} = false
+    for _, s := range strings.Fields(options) {
+        if strings.HasPrefix(s, "--") {
+            long = s
+        } else if strings.HasPrefix(s, "-") {
+            short = s
+        } else {
+            argcount = 1
+            arg = s
+        }
+    }
+    if argcount > 0 {
+        value = nil
+        if matched := Default_value(text); matched != nil {
+            value = *matched
+        }
+    }
+    o := new_option(short, long, argcount, value)
+    o.Arg = arg
+    o.Description = strings.TrimSpace(text)
+    return o
+}
+
+// The [default: ...] value of an option description, nil if none.
+func Default_value(description string) *string {
+    re := regexp.MustCompile(`(?i)\[default: (.*)\]`)
+    matched := re.FindStringSubmatch(description)
+    if matched == nil {
+        return nil
+    }
+    return &matched[1]
+}
+
+// Convert the Usage: section into a single pattern, each line starting with the
+// program name is an alternative. Returns the pattern and the program name.
+func formal_usage(section string) (string, string, error) {
+    _, _, section = partition(section, ":")
+    words := strings.Fields(section)
+    if len(words) == 0 {
+        return "", "", fmt.Errorf("no fields found in usage (perhaps a spacing error).")
+    }
+
+    result := "( "
+    for _, s := range words[1:] {
+        if s == words[0] {
+            result += ") | ( "
+        } else {
+            result += s + " "
+        }
+    }
+    result += ")"
+    return result, words[0], nil
+}
+
+func partition(s, sep string) (string, string, string) {
+    split := strings.SplitN(s, sep, 2)
+    if len(split) == 1 {
+        return s, "", ""
+    }
+    return split[0], sep, split[1]
+}
+
+// tokens of a pattern, an empty string is returned at the end
+type usage_tokens struct {
+    tokens []string
+}
+
+func (t *usage_tokens) current() string {
+    if len(t.tokens) > 0 {
+        return t.tokens[0]
+    }
+    return ""
+}
+
+func (t *usage_tokens) move() string {
+    tok := t.current()
+    if len(t.tokens) > 0 {
+        t.tokens = t.tokens[1:]
+    }
+    return tok
+}
+
+func tokenize_pattern(source string) *usage_tokens {
+    source = regexp.MustCompile(`([\[\]\(\)\|]|\.\.\.)`).ReplaceAllString(source, ` $1 `)
+    re := regexp.MustCompile(`\s+|(\S*<.*?>)`)
+    split := re.Split(source, -1)
+    match := re.FindAllStringSubmatch(source, -1)
+    result := []string{}
+    for i := range split {
+        if len(split[i]) > 0 {
+            result = append(result, split[i])
+        }
+        if i < len(match) && len(match[i][1]) > 0 {
+            result = append(result, match[i][1])
+        }
+    }
+    return &usage_tokens{result}
+}
+
+// expr ::= seq ( '|' seq )* ;
+func parse_expr(tokens *usage_tokens, options *[]*Pattern) ([]*Pattern, error) {
+    seq, err := parse_seq(tokens, options)
+    if err != nil {
+        return nil, err
+    }
+    if tokens.current() != "|" {
+        return seq, nil
+    }
+    result := []*Pattern{}
+    for {
+        if len(seq) > 1 {
+            result = append(result, new_branch(Pattern_required, seq...))
+        } else {
+            result = append(result, seq...)
+        }
+        if tokens.current() != "|" {
+            break
+        }
+        tokens.move()
+        seq, err = parse_seq(tokens, options)
+        if err != nil {
+            return nil, err
+        }
+    }
+    if len(result) > 1 {
+        return []*Pattern{new_branch(Pattern_either, result...)}, nil
+    }
+    return result, nil
+}
+
+// seq ::= ( atom [ '...' ] )* ;
+func parse_seq(tokens *usage_tokens, options *[]*Pattern) ([]*Pattern, error) {
+    result := []*Pattern{}
+    for {
+        tok := tokens.current()
+        if tok == "" || tok == "]" || tok == ")" || tok == "|" {
+            break
+        }
+        atom, err := parse_atom(tokens, options)
+        if err != nil {
+            return nil, err
+        }
+        if tokens.current() == "..." {
+            atom = []*Pattern{new_branch(Pattern_one_or_more, atom...)}
+            tokens.move()
+        }
+        result = append(result, atom...)
+    }
+    return result, nil
+}
+
+// atom ::= '(' expr ')' | '[' expr ']' | 'options' | long | shorts | argument | command ;
+func parse_atom(tokens *usage_tokens, options *[]*Pattern) ([]*Pattern, error) {
+    tok := tokens.current()
+    switch {
+    case tok == "(" || tok == "[":
+        tokens.move()
+        children, err := parse_expr(tokens, options)
+        if err != nil {
+            return nil, err
+        }
+        matching, t := ")", Pattern_required
+        if tok == "[" {
+            matching, t = "]", Pattern_optional
+        }
+        if moved := tokens.move(); moved != matching {
+            return nil, fmt.Errorf("unmatched '%s', expected: '%s' got: '%s'", tok, matching, moved)
+        }
+        return []*Pattern{new_branch(t, children...)}, nil
+    case tok == "options":
+        tokens.move()
+        return []*Pattern{new_branch(Pattern_options_shortcut)}, nil
+    case strings.HasPrefix(tok, "--") && tok != "--":
+        return parse_long(tokens, options)
+    case strings.HasPrefix(tok, "-") && tok != "-" && tok != "--":
+        return parse_shorts(tokens, options)
+    case strings.HasPrefix(tok, "<") && strings.HasSuffix(tok, ">") || IsUpper(tok):
+        return []*Pattern{{Type: Pattern_argument, Name: tokens.move()}}, nil
+    }
+    return []*Pattern{{Type: Pattern_command, Name: tokens.move(), Value: false}}, nil
+}
+
+// long ::= '--' chars [ ( ' ' | '=' ) chars ] ;
+func parse_long(tokens *usage_tokens, options *[]*Pattern) ([]*Pattern, error) {
+    long, eq, value := partition(tokens.move(), "=")
+    similar := []*Pattern{}
+    for _, o := range *options {
+        if o.Long == long {
+            similar = append(similar, o)
+        }
+    }
+    if len(similar) > 1 {
+        return nil, &Usage_option_error{long, "is not a unique prefix"}
+    }
+    if len(similar) == 0 {
+        argcount := 0
+        if eq == "=" {
+            argcount = 1
+        }
+        o := new_option("", long, argcount, false)
+        o.Arg = value
+        *options = append(*options, o)
+        return []*Pattern{o}, nil
+    }
+    o := similar[0]
+    if o.Argcount == 0 && eq == "=" {
+        return nil, &Usage_option_error{long, "must not have an argument"}
+    }
+    if o.Argcount > 0 && eq == "" && value == "" {
+        // the argument is the next token
+        if tok := tokens.current(); tok == "" || tok == "--" {
+            return nil, &Usage_option_error{long, "requires argument"}
+        }
+        value = tokens.move()
+    }
+    p := new_option(o.Short, o.Long, o.Argcount, o.Value)
+    p.Arg, p.Description = o.Arg, o.Description
+    if p.Arg == "" {
+        p.Arg = value
+    }
+    return []*Pattern{p}, nil
+}
+
+// shorts ::= '-' ( chars )* [ [ ' ' ] chars ] ;
+func parse_shorts(tokens *usage_tokens, options *[]*Pattern) ([]*Pattern, error) {
+    left := strings.TrimLeft(tokens.move(), "-")
+    parsed := []*Pattern{}
+    for left != "" {
+        short := "-" + left[0:1]
+        left = left[1:]
+        similar := []*Pattern{}
+        for _, o := range *options {
+            if o.Short == short {
+                similar = append(similar, o)
+            }
+        }
+        if len(similar) > 1 {
+            return nil, &Usage_option_error{short, fmt.Sprintf("is specified ambiguously %d times", len(similar))}
+        }
+        if len(similar) == 0 {
+            o := new_option(short, "", 0, false)
+            *options = append(*options, o)
+            parsed = append(parsed, o)
+            continue
+        }
+        o := similar[0]
+        p := new_option(short, o.Long, o.Argcount, o.Value)
+        p.Arg, p.Description = o.Arg, o.Description
+        parsed = append(parsed, p)
+        if o.Argcount > 0 {
+            // the argument is stuck to the option or is the next token
+            if left == "" {
+                if tok := tokens.current(); tok == "" || tok == "--" {
+                    return nil, &Usage_option_error{short, "requires argument"}
+                }
+                left = tokens.move()
+            }
+            if p.Arg == "" {
+                p.Arg = left
+            }
+            left = ""
+        }
+    }
+    return parsed, nil
+}
@@ -0,0 +1,151 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_usage.go
+//
+package main
+
+import (
+    "testing"
+    "reflect"
+    "strings"
+    "io/ioutil"
+    "regexp"
+    "sort"
+    "github.com/docopt/docopt-go"
+)
+
+func TestParse_usage(t *testing.T) {
+    doc := `Naval Fate.
+
+Usage:
+  naval_fate ship new <name>...
+  naval_fate ship <name> move <x> <y> [--speed=<kn>]
+  naval_fate mine (set|remove) <x> <y> [--moored|--drifting]
+  naval_fate -h | --help
+
+Options:
+  -h --help     Show this screen.
+  --speed=<kn>  Speed in knots [default: 10].
+  --moored      Moored (anchored) mine.
+  --drifting    Drifting mine.
+`
+    u, err := Parse_usage(doc)
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    if u.Prog != "naval_fate" {
+        t.Errorf("Parse_usage Prog, got: %v, want: naval_fate", u.Prog)
+    }
+
+    expect := []string{"ship", "new", "<name>", "move", "<x>", "<y>", "--speed",
+        "mine", "set", "remove", "--moored", "--drifting", "--help"}
+    if keys := u.Keys(); !reflect.DeepEqual(keys, expect) {
+        t.Errorf("Parse_usage Keys\ngot: '%v'\nwant: '%v'\n", keys, expect)
+    }
+
+    if len(u.Options) != 4 {
+        t.Fatalf("Parse_usage Options, got: %d options, want: 4", len(u.Options))
+    }
+    o := u.Options[1]
+    if o.Long != "--speed" || o.Argcount != 1 || o.Value != "10" || o.Description != "Speed in knots [default: 10]." {
+        t.Errorf("Parse_usage option --speed, got: %+v", o)
+    }
+    o = u.Options[0]
+    if o.Short != "-h" || o.Long != "--help" || o.Name != "--help" || o.Argcount != 0 || o.Value != false {
+        t.Errorf("Parse_usage option --help, got: %+v", o)
+    }
+
+    for _, bad := range []string{"no usage here", "usage: p (a", "usage: p\nusage: q", "usage:"} {
+        if _, err := Parse_usage(bad); err == nil {
+            t.Errorf("Parse_usage for '%s' must fail", bad)
+        }
+    }
+}
+
+func TestTransform(t *testing.T) {
+    u, err := Parse_usage("usage: p (-a | -b) (-c | -d)")
+    if err != nil {
+        t.Fatalf("Parse_usage error: %v", err)
+    }
+    cases := u.Pattern.transform()
+    expect := [][]string{{"-a", "-c"}, {"-a", "-d"}, {"-b", "-c"}, {"-b", "-d"}}
+    if len(cases) != len(expect) {
+        t.Fatalf("transform, got: %d cases, want: %d", len(cases), len(expect))
+    }
+    for i, c := range cases {
+        names := []string{}
+        for _, p := range c {
+            names = append(names, p.Name)
+        }
+        // order inside a case doesn't matter
+        sort.Strings(names)
+        if !reflect.DeepEqual(names, expect[i]) {
+            t.Errorf("transform case %d, got: %v, want: %v", i, names, expect[i])
+        }
+    }
+}
+
+// kind of value: repeatable, counter, flag or string
+func value_kind(v interface{}) string {
+    switch v.(type) {
+    case []string:
+        return "array"
+    case int:
+        return "int"
+    case bool:
+        return "bool"
+    }
+    return "string"
+}
+
+// Compare the model with docopt-go on all testcases.docopt: the same keys are
+// produced with the same kind of value.
+func TestParse_usage_testcases(t *testing.T) {
+    raw, err := ioutil.ReadFile("./testcases.docopt")
+    if err != nil {
+        t.Fatalf("cannot read testcases.docopt: %v", err)
+    }
+    fixtures := regexp.MustCompile(`(?m)#.*$`).ReplaceAllString(string(raw), "")
+
+    parser := &docopt.Parser{
+        HelpHandler: docopt.NoHelpHandler,
+        SkipHelpFlags: true,
+    }
+    checked := 0
+    for _, fixture := range strings.Split(fixtures, `r"""`)[1:] {
+        parts := strings.SplitN(fixture, `"""`, 2)
+        doc, body := parts[0], parts[1]
+        u, err := Parse_usage(doc)
+        if err != nil {
+            t.Errorf("Parse_usage error: %v\n%s", err, doc)
+            continue
+        }
+        leaves := u.Leaves()
+        for _, c := range strings.Split(body, "$")[1:] {
+            argv_line := strings.SplitN(strings.TrimSpace(c), "\n", 2)[0]
+            argv := strings.Fields(argv_line)[1:]
+            args, err := parser.ParseArgs(doc, argv, "")
+            if err != nil {
+                continue
+            }
+            keys := []string{}
+            for key := range args {
+                keys = append(keys, key)
+            }
+            d := &Docopts{Key_order: u.Keys()}
+            if ordered := d.Ordered_keys(args); !reflect.DeepEqual(ordered[:len(leaves)], u.Keys()) {
+                t.Errorf("Keys for '%s'\ngot: '%v'\nwant: '%v'\n%s", argv_line, u.Keys(), ordered, doc)
+                continue
+            }
+            for _, l := range leaves {
+                if args[l.Name] != nil && value_kind(args[l.Name]) != value_kind(l.Value) {
+                    t.Errorf("Value for '%s' '%s', got: %v, want: %v\n%s", argv_line, l.Name, l.Value, args[l.Name], doc)
+                }
+            }
+            checked++
+        }
+    }
+    if checked == 0 {
+        t.Errorf("no testcase checked")
+    }
+}
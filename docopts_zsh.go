@@ -0,0 +1,108 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// docopts_zsh.go: zsh output backend, see: --shell=zsh
+//
+package main
+
+import (
+    "fmt"
+    "github.com/docopt/docopt-go"
+    "strings"
+)
+
+// output zsh associative array, suitable for eval.
+// zsh parses assoc subscripts as if double quoted, so keys are not assigned one
+// by one but with a single list assignment of quoted key value pairs.
+// Repeatable arguments reuse the fake nested layout of Print_bash_args() but
+// are 1-indexed like zsh arrays:
+// assoc[key,#]=length
+// assoc[key,i]=value
+// 'i' is an integer from 1 to length
+func (d *Docopts) Print_zsh_args(assoc string, args docopt.Opts) {
+    // native array names are checked before any output, see: --arrays
+    var native_names map[string]string
+    if d.Arrays == "native" {
+        var err error
+        native_names, err = d.Native_array_names(assoc, args)
+        if err != nil {
+            docopts_error("%v", err)
+        }
+    }
+
+    declare := "typeset"
+    if d.Exit_function {
+        declare = "local"
+    }
+    if d.Output_declare {
+        fmt.Fprintf(out, "%s -A %s\n", declare, assoc)
+    }
+
+    fmt.Fprintf(out, "%s=(\n", assoc)
+    for _, key := range d.Ordered_keys(args) {
+        value := args[key]
+        if val_arr, ok := value.([]string); ok {
+            for index, v := range val_arr {
+                fmt.Fprintf(out, "'%s,%d' %s\n", Shellquote(key), index + 1, d.To_zsh(v))
+            }
+            fmt.Fprintf(out, "'%s,#' %d\n", Shellquote(key), len(val_arr))
+        } else {
+            fmt.Fprintf(out, "'%s' %s\n", Shellquote(key), d.To_zsh(value))
+        }
+    }
+    fmt.Fprintf(out, ")\n")
+
+    for _, key := range d.Ordered_keys(args) {
+        if name, ok := native_names[key]; ok {
+            fmt.Fprintf(out, "%s -a %s=%s\n", declare, name, d.To_zsh(args[key]))
+        }
+    }
+}
+
+// Performs output for zsh globals, names are mangled the same way as
+// Print_bash_global(). Repeatable arguments are zsh arrays declared with
+// typeset -a, global unless Docopts.Exit_function is true. --export is handled
+// as in Print_bash_global().
+func (d *Docopts) Print_zsh_global(args docopt.Opts) {
+    names := d.Shell_global_names(args)
+
+    declare := d.Global_declare()
+    array_declare := "typeset -ga "
+    if d.Exit_function && d.Mangle_key {
+        array_declare = "local -a "
+    }
+    if ! d.Mangle_key {
+        array_declare = ""
+    }
+
+    for _, key := range d.Ordered_keys(args) {
+        value := args[key]
+        if val_arr, ok := value.([]string); ok {
+            fmt.Fprintf(out, "%s%s=%s\n", array_declare, names[key], d.To_zsh(value))
+            if d.Export && d.Mangle_key {
+                fmt.Fprintf(out, "%s", d.Export_array_source(names, key, val_arr))
+            }
+        } else {
+            fmt.Fprintf(out, "%s%s=%s\n", declare, names[key], d.To_shell(value))
+        }
+    }
+}
+
+// Convert a parsed type to a zsh word, same as Docopts.To_shell() except that
+// nil is an empty quoted string, so it can be used as a list element.
+func (d *Docopts) To_zsh(v interface{}) string {
+    switch v.(type) {
+    case nil:
+        return "''"
+    case []string:
+        arr := v.([]string)
+        if len(arr) == 0 {
+            return "()"
+        }
+        words := make([]string, len(arr))
+        for i, e := range arr {
+            words[i] = d.To_zsh(e)
+        }
+        return fmt.Sprintf("(%s)", strings.Join(words, " "))
+    }
+    return d.To_shell(v)
+}
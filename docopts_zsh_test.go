@@ -0,0 +1,128 @@
+// vim: set ts=4 sw=4 sts=4 et:
+//
+// unit test for docopts_zsh.go
+//
+package main
+
+import (
+    "testing"
+    "bytes"
+)
+
+func TestPrint_zsh_args(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d := &Docopts{
+        Global_prefix: "",
+        Mangle_key: true,
+        Output_declare: true,
+        Shell: "zsh",
+    }
+
+    args := map[string]interface{}{
+        "--counter": 2,
+        "--out": "it's",
+        "--in": nil,
+        "FILE": []string{"pipo", "molo"},
+    }
+    d.Print_zsh_args("args", args)
+    res := out.(*bytes.Buffer).String()
+    expect := "typeset -A args\nargs=(\n" +
+        "'--counter' 2\n" +
+        "'--in' ''\n" +
+        "'--out' 'it'\\''s'\n" +
+        "'FILE,1' 'pipo'\n" +
+        "'FILE,2' 'molo'\n" +
+        "'FILE,#' 2\n" +
+        ")\n"
+    if res != expect {
+        t.Errorf("Print_zsh_args\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+    out.(*bytes.Buffer).Reset()
+
+    d.Exit_function = true
+    d.Print_zsh_args("args", map[string]interface{}{"cmd": true})
+    res = out.(*bytes.Buffer).String()
+    expect = "local -A args\nargs=(\n'cmd' true\n)\n"
+    if res != expect {
+        t.Errorf("Print_zsh_args with Exit_function\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
+
+func TestPrint_zsh_global(t *testing.T) {
+    // replace out (os.Stdout) by a buffer
+    bak := out
+    out = new(bytes.Buffer)
+    defer func() { out = bak }()
+
+    d := &Docopts{
+        Global_prefix: "",
+        Mangle_key: true,
+        Output_declare: true,
+        Shell: "zsh",
+    }
+
+    args := map[string]interface{}{
+        "--counter": 2,
+        "<x>": []string{},
+        "FILE": []string{"pipo", "molo"},
+    }
+    d.Print_zsh_global(args)
+    res := out.(*bytes.Buffer).String()
+    expect := "counter=2\ntypeset -ga x=()\ntypeset -ga FILE=('pipo' 'molo')\n"
+    if res != expect {
+        t.Errorf("Print_zsh_global\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+    out.(*bytes.Buffer).Reset()
+
+    d.Exit_function = true
+    d.Print_zsh_global(args)
+    res = out.(*bytes.Buffer).String()
+    expect = "local counter=2\nlocal -a x=()\nlocal -a FILE=('pipo' 'molo')\n"
+    if res != expect {
+        t.Errorf("Print_zsh_global with Exit_function\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
+
+func TestTo_zsh(t *testing.T) {
+    tables := []struct {
+        input interface{}
+        expect string
+    }{
+        {nil, "''"},
+        {true, "true"},
+        {"a b", "'a b'"},
+        {[]string{}, "()"},
+        {[]string{"a", "it's"}, "('a' 'it'\\''s')"},
+    }
+    d := &Docopts{Shell: "zsh"}
+    for _, table := range tables {
+        if res := d.To_zsh(table.input); res != table.expect {
+            t.Errorf("To_zsh(%v), got: %v, want: %v", table.input, res, table.expect)
+        }
+    }
+}
+
+func TestReserved_name_zsh(t *testing.T) {
+    if Reserved_name("path", "bash") == "" {
+        t.Errorf("Reserved_name('path', 'bash') should be reserved")
+    }
+    if res := Reserved_name("argv", "bash"); res != "" {
+        t.Errorf("Reserved_name('argv', 'bash'), got: %v, want: ''", res)
+    }
+    if Reserved_name("argv", "zsh") == "" {
+        t.Errorf("Reserved_name('argv', 'zsh') should be reserved")
+    }
+}
+
+func TestBash_fail_source_zsh(t *testing.T) {
+    d := &Docopts{Shell: "zsh"}
+    res := d.Bash_fail_source("Usage: p [--sep=\\n]", false, 0)
+    expect := "printf '%s\\n' 'Usage: p [--sep=\\n]'\nexit 0\n"
+    if res != expect {
+        t.Errorf("Bash_fail_source zsh\ngot: '%v'\nwant: '%v'\n", res, expect)
+    }
+}
@@ -10,6 +10,7 @@ type TestString struct {
     Input map[string]interface{}
     Expect_args []string
     Expect_global  []string
+    Expect_json string
 }
 
 func (t TestString) ToString() string {
@@ -35,6 +36,7 @@ func (t TestString) toString() (str string) {
   str += fmt.Sprintf("}\n")
 
   str += fmt.Sprintf("Expect_global : %v\n", t.Expect_global)
+  str += fmt.Sprintf("Expect_json : %v\n", t.Expect_json)
 
   return str
 }
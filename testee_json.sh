@@ -0,0 +1,36 @@
+#!/usr/bin/env bash
+#
+# Testee script for docopts.  This script reads an arbitrary docopts usage
+# from standard input and uses it to parse whatever arguments are passed to it
+# into a JSON object, with docopts --json.
+#
+# Pass this file as an argument to `language_agnostic_tester.py` to test
+# a `docopts` binary located in the same directory. testee.sh tests the Bash 4
+# associative array output of -A.
+#
+# The JSON output keeps the parsed types: `--speed=2` maps to `"--speed": "2"`
+# and `--speed --speed` to `"--speed": 2`, which can't be distinguished from
+# the Bash 4 associative array, this was previously worked around with
+# docopt_get_raw_value().
+#
+# There is currently no way to automatically test the operation mode of
+# docopts that name-mangles elements into Bash variables, as this
+# transformation cannot be deterministically reversed into a format
+# language_agnostic_tester.py expects.
+#
+# Usage:
+#  python language_agnostic_tester.py ./testee_json.sh [ID_OF_THE_TEST]
+#  # usage on stdin, args on command line
+#  echo "usage: prog (go <direction> --speed=<km/h>)..." | ./testee_json.sh go left --speed=5  go right --speed=9
+#
+# To get ID_OF_THE_TEST:
+#  grep -E '^\{|"user' testcases.docopt | cat -n | less
+#
+# Note that `language_agnostic_tester.py` is only compatible with
+# Python 2.7.
+
+# help or usage error: docopts exits non 0, see: docopts fail
+if ! json=$(./docopts --json -h - : "$@" < /dev/stdin) ; then
+    json='"user-error"'
+fi
+echo "$json"
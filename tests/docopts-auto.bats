@@ -114,3 +114,579 @@ PATH=..:$PATH
     [[ ${lines[0]} != "declare -A myargs" ]]
 }
 
+
+@test "--json" {
+    run docopts --json -h "usage: p [-v...] [--out=<f>] [--in=<i>] FILE..." : -vv --out=x one two
+    echo "status=$status"
+    echo "output=$output"
+    [[ $status -eq 0 ]]
+    [[ ${#lines[@]} -eq 1 ]]
+    [[ "$output" == '{"-v":2,"--out":"x","--in":null,"FILE":["one","two"]}' ]]
+    run docopts --json --sort=alpha -h "usage: p [-v...] [--out=<f>] [--in=<i>] FILE..." : -vv --out=x one two
+    [[ "$output" == '{"--in":null,"--out":"x","-v":2,"FILE":["one","two"]}' ]]
+
+    # invalid UTF-8 can't be a JSON string
+    run docopts --json -h "usage: p FILE..." : one $'\xff'
+    echo "output=$output"
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: --json: value of 'FILE' is not valid UTF-8: \"\\xff\"" ]]
+}
+
+@test "get has count" {
+    export DOCOPTS_JSON=$(docopts --json -h "usage: p [--code=<c>] [-v...] [--out=<f>] FILE..." : --code=x -vv one 'two three')
+    run docopts get --code
+    [[ $status -eq 0 ]]
+    [[ "$output" == x ]]
+    run docopts get FILE 1
+    [[ "$output" == 'two three' ]]
+    run docopts count FILE
+    [[ "$output" == 2 ]]
+    run docopts count -v
+    [[ "$output" == 2 ]]
+    run docopts has --code
+    [[ $status -eq 0 ]]
+    run docopts has --out
+    [[ $status -eq 1 ]]
+    run docopts get --cod
+    [[ $status -eq 1 ]]
+    regexp='docopts:error:'
+    [[ "$output" =~ $regexp ]]
+
+    # other variable name
+    SOME_JSON=$(docopts --json -h "usage: p --code=<c>" : --code=y)
+    export SOME_JSON
+    run docopts --env SOME_JSON get --code
+    [[ "$output" == y ]]
+    DOCOPTS_JSON_VAR=SOME_JSON run docopts get --code
+    [[ "$output" == y ]]
+}
+
+@test "--json exit code and fail" {
+    usage="usage: p [-h] FILE..."
+    run docopts --json -h "$usage" : one
+    [[ $status -eq 0 ]]
+
+    # help
+    run docopts --json -h "$usage" : -h
+    [[ $status -eq 42 ]]
+    export DOCOPTS_JSON=$output
+    run docopts fail
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "echo 'usage: p [-h] FILE...'" ]]
+    [[ "${lines[-1]}" == "exit 0" ]]
+
+    # error
+    run docopts --json -h "$usage" :
+    [[ $status -eq 1 ]]
+    run docopts --json --error-code=3 -h "$usage" :
+    [[ $status -eq 3 ]]
+    export DOCOPTS_JSON=$output
+    run docopts fail
+    [[ $status -eq 0 ]]
+    regexp="^echo 'error:"
+    [[ "${lines[0]}" =~ $regexp ]]
+    [[ "${lines[-1]}" == "exit 64" ]]
+    run bash -c 'eval "$(docopts fail)" ; echo not reached'
+    [[ $status -eq 64 ]]
+    [[ "${lines[0]}" == "error: " ]]
+
+    # no parsed arguments
+    run docopts get FILE
+    [[ $status -eq 1 ]]
+}
+
+@test "--function nested calls" {
+    inner() {
+        eval "$(docopts --function -A args -h "usage: inner [-v] FILE..." : "$@")"
+        echo "inner ${args[FILE,0]}"
+    }
+    outer() {
+        eval "$(docopts --function -G outer -h "usage: outer [--name=<n>] <cmd>" : "$@")"
+        inner "$outer_cmd" || return $?
+        echo "outer $outer_name"
+    }
+
+    run outer --name=x f1
+    echo "$output"
+    [[ $status -eq 0 ]]
+    [[ ${lines[0]} == "inner f1" ]]
+    [[ ${lines[1]} == "outer x" ]]
+
+    # variables are local to the function
+    outer --name=x f1 > /dev/null
+    [[ -z "$outer_cmd" ]]
+    [[ -z "$outer_name" ]]
+    [[ -z "${args[*]}" ]]
+
+    # error branch returns to the caller which continues
+    status=0
+    outer > /dev/null 2>&1 || status=$?
+    [[ $status -eq 64 ]]
+    # the error comes from inner, outer returns its status
+    status=0
+    outer -- -v > /dev/null 2>&1 || status=$?
+    [[ $status -eq 64 ]]
+
+    # help branch
+    run outer -h
+    [[ $status -eq 0 ]]
+    [[ ${lines[0]} == "usage: outer [--name=<n>] <cmd>" ]]
+    [[ ${#lines[@]} -eq 1 ]]
+
+    # docopts fail
+    f() {
+        local DOCOPTS_JSON
+        DOCOPTS_JSON=$(docopts --json -h "usage: f FILE" : "$@") || eval "$(DOCOPTS_JSON=$DOCOPTS_JSON docopts --function fail)"
+        echo "f $DOCOPTS_JSON"
+    }
+    f > /dev/null 2>&1 || status=$?
+    [[ $status -eq 64 ]]
+    run f one
+    [[ "$output" == 'f {"FILE":"one"}' ]]
+}
+
+@test "mangled names collision" {
+    run docopts -h "usage: p [--dry-run] [<dry_run>] [-v] [v]" : --dry-run
+    echo "$output"
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: mangled names collision: '--dry-run', '<dry_run>' => 'dry_run'; '-v', 'v' => 'v'" ]]
+
+    eval "$(docopts --mangle-suffix -h "usage: p [--dry-run] [<dry_run>] [-v] [v]" : --dry-run -v)"
+    [[ $dry_run_opt == true ]]
+    [[ -z $dry_run_arg ]]
+    [[ $v_opt == true ]]
+    [[ $v_cmd == false ]]
+}
+
+@test "--reserved" {
+    # default: error
+    run docopts -h "usage: p [--home=<h>] <path>" : --home=/tmp ./here
+    echo "$output"
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: '--home' => 'home' would overwrite the shell variable HOME, use -G <prefix> or --reserved=prefix" ]]
+
+    run docopts --reserved=warn -h "usage: p <path>" : ./here
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: --reserved: unknown policy: 'warn'" ]]
+
+    run docopts --reserved=prefix -h "usage: p [--home=<h>] <path>" : --home=/tmp ./here
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "_home='/tmp'" ]]
+    [[ "${lines[1]}" == "_path='./here'" ]]
+
+    run docopts --reserved=allow -h "usage: p <path>" : ./here
+    [[ "$output" == "path='./here'" ]]
+
+    run docopts -G ARGS -h "usage: p <path>" : ./here
+    [[ "$output" == "ARGS_path='./here'" ]]
+}
+
+@test "--shell=zsh" {
+    run docopts --shell=zsh -A args -h "usage: p [-v...] [--out=<f>] FILE..." : -vv --out="it's" one two
+    echo "$output"
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "typeset -A args" ]]
+    [[ "${lines[1]}" == "args=(" ]]
+    [[ "${lines[2]}" == "'-v' 2" ]]
+    [[ "${lines[4]}" == "'FILE,1' 'one'" ]]
+    [[ "${lines[6]}" == "'FILE,#' 2" ]]
+
+    run docopts --shell=zsh -h "usage: p [--argv] FILE..." : --argv one
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: '--argv' => 'argv' would overwrite the shell variable argv, use -G <prefix> or --reserved=prefix" ]]
+
+    run docopts --shell=csh -h "usage: p" :
+    [[ $status -eq 1 ]]
+
+    if ! command -v zsh > /dev/null ; then
+        skip "zsh not found"
+    fi
+    run zsh -c 'eval "$(docopts --shell=zsh -h "usage: p [--out=<f>] FILE..." : --out="a b" one "t w o")"; echo "$out ${#FILE} ${FILE[2]}"'
+    [[ "$output" == "a b 2 t w o" ]]
+    run zsh -c 'eval "$(docopts --shell=zsh -A args -h "usage: p [--out=<f>] FILE..." : one "t]o")"; echo "${args[--out]}|${args[FILE,#]}|${args[FILE,2]}"'
+    [[ "$output" == "|2|t]o" ]]
+}
+
+@test "--shell=posix" {
+    run docopts --shell=posix -h "usage: p [--out=<f>] FILE..." : --out="it's" one two
+    echo "$output"
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "out='it'\''s'" ]]
+    [[ "${lines[1]}" == "FILE_COUNT=2" ]]
+    [[ "${lines[2]}" == "FILE_0='one'" ]]
+    [[ "${lines[3]}" == "FILE_1='two'" ]]
+
+    run docopts --shell=posix --no-mangle -h "usage: p FILE..." : one
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: --no-mangle: POSIX sh has no associative array, keys can't be output as variables, use -G <prefix> instead, or --json" ]]
+
+    run docopts --shell=posix -A args -h "usage: p FILE..." : one
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: -A: POSIX sh has no associative array, use global variables with -G <prefix> instead, or --json" ]]
+
+    run docopts --set-positional=FILE -h "usage: p FILE..." : one
+    [[ $status -eq 1 ]]
+
+    if ! command -v dash > /dev/null ; then
+        skip "dash not found"
+    fi
+    run dash -c 'eval "$(docopts --shell=posix --set-positional=FILE -h "usage: p [--out=<f>] FILE..." : --out="a b" one "t w\\no")"; printf "%s %s %s" "$out" $# "$2"'
+    [[ "$output" == 'a b 2 t w\no' ]]
+    # message is output verbatim, no backslash sequence
+    run dash -c 'eval "$(docopts --shell=posix -h "usage: p FILE\\n" : a b)"; echo not reached'
+    [[ $status -eq 64 ]]
+    [[ "${lines[1]}" == 'usage: p FILE\n' ]]
+}
+
+@test "--shell=fish" {
+    run docopts --shell=fish -h "usage: p [--out=<f>] FILE..." : --out="it's" one two
+    echo "$output"
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "set -g out 'it\\'s'" ]]
+    [[ "${lines[1]}" == "set -g FILE 'one' 'two'" ]]
+
+    run docopts --shell=fish --function -h "usage: p FILE" :
+    [[ "${lines[0]}" == "printf '%s\n' 'error: " ]]
+    [[ "${lines[1]}" == "usage: p FILE' >&2" ]]
+    [[ "${lines[2]}" == "return 64" ]]
+
+    run docopts --shell=fish -A args -h "usage: p FILE..." : one
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: -A: fish has no associative array, use global variables with -G <prefix> instead, or --json" ]]
+
+    if ! command -v fish > /dev/null ; then
+        skip "fish not found"
+    fi
+    run fish -c 'function f; eval (docopts --shell=fish --function -h "usage: f [--out=<f>] FILE..." : $argv | string collect); printf "%s|%s|%s" $out (count $FILE) $FILE[2]; end; f --out="a\\b" one "t w o"; set -q out; or echo " unset"'
+    [[ "$output" == 'a\b|2|t w o unset' ]]
+    run fish -c 'eval (docopts --shell=fish -h "usage: p FILE" : | string collect); echo not reached'
+    [[ $status -eq 64 ]]
+}
+
+@test "--arrays=native" {
+    eval "$(docopts -A ARGS --arrays=native -h "usage: p [--tag=<t>...] FILE..." : --tag=x "a b" c)"
+    [[ ${ARGS['FILE,#']} -eq 2 ]]
+    [[ ${#ARGS_FILE[@]} -eq 2 ]]
+    [[ "${ARGS_FILE[0]}" == "a b" ]]
+    [[ "${ARGS_FILE[1]}" == "c" ]]
+    [[ "${ARGS_tag[*]}" == "x" ]]
+
+    eval "$(docopts -A ARGS --arrays=native --array-name='{name}_list' -h "usage: p [<x>...]" :)"
+    [[ ${#x_list[@]} -eq 0 ]]
+
+    run docopts -A ARGS --arrays=native --array-name='{name}' -h "usage: p <path>..." : a
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: '<path>' => 'path' would overwrite the shell variable PATH, use --array-name or --reserved=prefix" ]]
+
+    run docopts -A ARGS --arrays=nested -h "usage: p" :
+    [[ $status -eq 1 ]]
+}
+
+@test "--quoting round trip" {
+    local value=$'a\nb\t\'c\' \e[31m\\x01 $HOME \xff'
+    local q
+    for q in single ansi-c auto ; do
+        eval "$(docopts --quoting=$q -A ARGS -h "usage: p [--out=<f>] FILE..." : --out="$value" "$value" plain)"
+        [[ "${ARGS[--out]}" == "$value" ]]
+        [[ "${ARGS[FILE,0]}" == "$value" ]]
+        [[ "${ARGS[FILE,1]}" == "plain" ]]
+
+        eval "$(docopts --quoting=$q -h "usage: p [--out=<f>] FILE..." : --out="$value" "$value")"
+        [[ "$out" == "$value" ]]
+        [[ "${FILE[0]}" == "$value" ]]
+    done
+
+    # ansi-c output is one line per value
+    run docopts --quoting=ansi-c -h "usage: p <x>" : $'a\nb'
+    [[ "$output" == "x=\$'a\\nb'" ]]
+
+    run docopts --quoting=ansi-c --shell=fish -h "usage: p <x>" : a
+    [[ $status -eq 1 ]]
+}
+
+@test "docopts exec" {
+    local usage="usage: p [-v...] [--out=<f>] FILE..."
+    run docopts exec -h "$usage" : -vv --out='a b' one 't w o' -- bash --norc -c 'echo "$v|$out|$FILE_COUNT|$FILE_0|$FILE_1"'
+    echo "$output"
+    [[ $status -eq 0 ]]
+    [[ "$output" == "2|a b|2|one|t w o" ]]
+
+    run docopts exec --env-prefix=P --env-arrays=newline -h "$usage" : a b -- bash --norc -c 'echo "$P_FILE_COUNT|$P_FILE"'
+    [[ "${lines[0]}" == "2|a" ]]
+    [[ "${lines[1]}" == "b" ]]
+
+    # the command gets its own arguments, including --
+    run docopts exec -h "$usage" : a -- printf '%s,' -- x
+    [[ "$output" == "--,x," ]]
+
+    run docopts exec -h "$usage" : --help -- false
+    [[ $status -eq 0 ]]
+    [[ "$output" == "$usage" ]]
+
+    run docopts exec -h "$usage" : -- true
+    [[ $status -eq 64 ]]
+
+    run docopts exec -h "$usage" : a
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: exec: no command given, use: : [<argv>...] -- command [args...]" ]]
+
+    run docopts exec --no-mangle -h "$usage" : a -- env
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: --no-mangle: exec passes environment variables, keys can't be used as names, use --env-prefix=<prefix> instead" ]]
+}
+
+@test "--export" {
+    eval "$(docopts --export -h "usage: p [--out=<f>] FILE..." : --out=x a 'b c')"
+    run bash --norc -c 'echo "$out|$FILE_COUNT|$FILE_1"'
+    [[ "$output" == "x|2|b c" ]]
+    [[ "${FILE[1]}" == "b c" ]]
+
+    run docopts --export -A args -h "usage: p FILE" : a
+    [[ $status -eq 1 ]]
+}
+
+@test "--from-script" {
+    tmp=./tmp-from-script.sh
+    cat <<'EOF' > $tmp
+#!/usr/bin/env bash
+#? rock 0.1.0
+##? Usage: rock [--verbose] <argv>...
+##?
+##?   --verbose  Generate verbose messages.
+##?   --version  Print program version.
+EOF
+    run docopts --from-script=$tmp --marker='##?' --version-marker='#?' : --verbose a
+    echo "$output"
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "verbose=true" ]]
+
+    run docopts --from-script=$tmp --marker='##?' --version-marker='#?' : --version
+    [[ "${lines[0]}" == "echo 'rock 0.1.0'" ]]
+
+    run docopts --from-script=$tmp : a
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: --from-script: no usage found in $tmp: expected a comment block starting with '# Usage:'" ]]
+
+    run docopts --from-script=../examples/naval_fate.sh version
+    [[ $status -eq 0 ]]
+    [[ -z "$output" ]]
+    rm -f $tmp
+}
+
+@test "--generate-completion" {
+    run docopts --generate-completion --from-script=../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ "${lines[${#lines[@]}-1]}" == "complete -F _naval_fate naval_fate.sh" ]]
+
+    source <(docopts --generate-completion --from-script=../examples/naval_fate.sh)
+    COMP_WORDS=(naval_fate.sh ship "")
+    COMP_CWORD=2
+    _naval_fate
+    [[ "${COMPREPLY[*]}" == "new shoot" ]]
+
+    COMP_WORDS=(naval_fate.sh --m)
+    COMP_CWORD=1
+    _naval_fate
+    [[ "${COMPREPLY[*]}" == "--moored" ]]
+
+    run docopts --generate-completion -h "usage: p (a"
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: --generate-completion: unmatched '(', expected: ')' got: ''" ]]
+}
+
+@test "--generate-completion=zsh|fish" {
+    run docopts --generate-completion=zsh --from-script=../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "#compdef naval_fate.sh" ]]
+    [[ "$output" == *"'(--drifting)--moored[Moored (anchored) mine.]'"* ]]
+
+    run docopts --generate-completion=fish --from-script=../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ "$output" == *"complete -c naval_fate.sh -l speed -x -d 'Speed in knots [default: 10].'"* ]]
+
+    run docopts --generate-completion=tcsh -h "usage: p"
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: --generate-completion: unsupported shell: 'tcsh'" ]]
+
+    if command -v zsh > /dev/null ; then
+        docopts --generate-completion=zsh --from-script=../examples/naval_fate.sh > ./tmp-_naval_fate
+        run zsh -n ./tmp-_naval_fate
+        rm -f ./tmp-_naval_fate
+        [[ $status -eq 0 ]]
+    fi
+    if ! command -v fish > /dev/null ; then
+        skip "fish not found"
+    fi
+    run fish -c 'docopts --generate-completion=fish --from-script=../examples/naval_fate.sh | source; complete -C "naval_fate.sh ship "; complete -C "naval_fate.sh --mo"'
+    [[ "${lines[0]}" == "new" ]]
+    [[ "${lines[1]}" == "shoot" ]]
+    [[ "${lines[2]}" == --moored* ]]
+}
+
+@test "docopts complete" {
+    usage="usage: p (add|remove) [--force] <file>...
+           p list [--all] [--format=<fmt>]"
+    run docopts complete -h "$usage" : p ""
+    [[ $status -eq 0 ]]
+    [[ "$output" == $'add\nremove\nlist' ]]
+
+    run docopts complete -h "$usage" : p list --
+    [[ "$output" == $'--all\n--format' ]]
+
+    run docopts complete -h "$usage" --cword=2 : p add --
+    [[ "$output" == "--force" ]]
+
+    run docopts complete -h "$usage" : p list --format ""
+    [[ "$output" == "<fmt>" ]]
+
+    run docopts complete -h "$usage" : p remove x ""
+    [[ "$output" == "<file>" ]]
+
+    # with --options-first, options are positional after the first one
+    run docopts complete -O -h "usage: p [-v] <cmd> [<args>...]" : p run -
+    [[ "$output" == "<args>" ]]
+
+    run docopts complete -h "$usage" --cword=x : p
+    [[ $status -eq 1 ]]
+}
+
+@test "--generate-man" {
+    SOURCE_DATE_EPOCH= run docopts --generate-man -V "Naval Fate 2.0" --from-script=../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == '.TH NAVAL_FATE.SH 1 "" "Naval Fate 2.0"' ]]
+    [[ "${lines[1]}" == ".SH NAME" ]]
+    # the description precedes the usage in the comments of the script
+    [[ "${lines[2]}" == 'naval_fate.sh \- Naval Fate.' ]]
+    [[ "${lines[3]}" == ".SH SYNOPSIS" ]]
+    [[ "$output" == *'\fB\-\-speed\fR=\fI<kn>\fR'$'\n''Speed in knots [default: 10].'* ]]
+
+    SOURCE_DATE_EPOCH=1700000000 run docopts --generate-man -V "Naval Fate 2.0" --from-script=../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == '.TH NAVAL_FATE.SH 1 "2023-11-14" "Naval Fate 2.0"' ]]
+
+    run docopts --generate-man -h "no usage"
+    [[ $status -eq 1 ]]
+    [[ "$output" == 'docopts:error: --generate-man: "usage:" (case-insensitive) not found.' ]]
+}
+
+@test "--generate-doc" {
+    run docopts --generate-doc=markdown -V "Naval Fate 2.0" --from-script=../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "# naval_fate.sh" ]]
+    [[ "${lines[1]}" == "_Naval Fate 2.0_" ]]
+    [[ "${lines[2]}" == "Naval Fate." ]]
+    [[ "$output" == *'| <a id="option-speed"></a> | `--speed` | `<kn>` | `10` | Speed in knots. |'* ]]
+
+    tmp=tmp-generate-doc
+    rm -rf $tmp
+    run docopts --generate-doc=html --output-dir=$tmp \
+        --from-script=../examples/naval_fate.sh --from-script=../examples/calculator_example.sh
+    [[ $status -eq 0 ]]
+    [[ "${lines[*]}" == "$tmp/naval_fate.sh.html $tmp/calculator_example.sh.html $tmp/index.html" ]]
+    grep -q '<li><a href="calculator_example.sh.html">calculator_example.sh</a>: Not a serious example.</li>' $tmp/index.html
+    grep -q '<tr id="option-speed">' $tmp/naval_fate.sh.html
+    grep -q "<p>Naval Fate.</p>" $tmp/naval_fate.sh.html
+    rm -rf $tmp
+
+    run docopts --generate-doc=markdown --from-script=../examples/naval_fate.sh \
+        --from-script=../examples/calculator_example.sh
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: --generate-doc: --output-dir is required with more than one script" ]]
+
+    run docopts --generate-doc=pdf -h "Usage: prog"
+    [[ $status -eq 1 ]]
+    [[ "$output" == "docopts:error: --generate-doc: unsupported format: 'pdf'" ]]
+}
+
+@test "docopts lint" {
+    run docopts lint --from-script=../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ -z "$output" ]]
+
+    run docopts lint --from-script=../examples/naval_fate.sh --from-script=../examples/counted_example.sh
+    [[ $status -eq 1 ]]
+    [[ "$output" == "../examples/counted_example.sh:6:30: warning: --path: '--path' => 'path' would overwrite the shell variable PATH, use -G <prefix> or --reserved=prefix" ]]
+    run docopts lint -G ARGS --from-script=../examples/counted_example.sh
+    [[ $status -eq 0 ]]
+
+    run docopts lint -h - <<< $'Usage: prog [options]\n\nOptions:\n  -v  Verbose.\n  -v, --very  Very.\n'
+    [[ $status -eq 2 ]]
+    [[ "$output" == "<stdin>:5:3: error: -v is described more than once" ]]
+
+    # a parse error is reported at the option, unused options are still found
+    run docopts lint -h - <<< $'Usage: prog -v\n\nOptions:\n  -v  Verbose.\n  -v, --very  Very.\n  --never  Never.\n'
+    [[ $status -eq 2 ]]
+    [[ "${lines[0]}" == "<stdin>:5:3: error: -v is specified ambiguously 2 times" ]]
+    [[ "${lines[2]}" == "<stdin>:6:3: warning: --never is described but not used by any pattern, add it or [options] to the usage" ]]
+
+    run docopts lint -h "no usage"
+    [[ $status -eq 2 ]]
+    [[ "$output" == '<help>: error: "usage:" (case-insensitive) not found' ]]
+}
+
+@test "docopts check" {
+    run docopts check ../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ -z "$output" ]]
+
+    tmp=./tmp-check.sh
+    cat << 'EOF' > $tmp
+#!/bin/bash
+# Usage: check.sh [--output=FILE] [--dry-run] <src>
+eval "$(docopts -G opt -h "$(docopt_get_help_string $0)" : "$@")"
+echo "$opt_ouput $opt_src"
+EOF
+    run docopts check $tmp
+    [[ $status -eq 2 ]]
+    [[ "${lines[0]}" == "$tmp:2:20: warning: --output is never read" ]]
+    [[ "${lines[1]}" == "$tmp:2:36: warning: --dry-run is never read" ]]
+    [[ "${lines[2]}" == "$tmp:4:7: error: \$opt_ouput: not defined by the usage, did you mean \$opt_output (--output)?" ]]
+
+    run docopts check -A ARGS $tmp
+    [[ $status -eq 1 ]]
+    [[ ${#lines[@]} -eq 2 ]]
+    rm -f $tmp
+}
+
+@test "docopts ast" {
+    run docopts ast --from-script=../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "{" ]]
+    [[ "${lines[1]}" == '  "ast_version": 1,' ]]
+    [[ "${lines[2]}" == '  "prog": "naval_fate.sh",' ]]
+    [[ "$output" == *'"name": "--speed",'* ]]
+    [[ "$output" == *'"default": "10"'* ]]
+
+    run docopts ast -h "Usage: prog ("
+    [[ $status -ne 0 ]]
+    [[ "$output" == *"ast: unmatched '('"* ]]
+}
+
+@test "docopts schema" {
+    run docopts schema --from-script=../examples/naval_fate.sh
+    [[ $status -eq 0 ]]
+    [[ "${lines[1]}" == '  "$schema": "https://json-schema.org/draft/2020-12/schema",' ]]
+    [[ "$output" == *'"<name>": {'* ]]
+    [[ "$output" == *'"additionalProperties": false,'* ]]
+    [[ "$output" == *'"allOf": ['* ]]
+
+    run docopts schema -h "Usage: prog ("
+    [[ $status -ne 0 ]]
+    [[ "$output" == *"schema: unmatched '('"* ]]
+}
+
+@test "--explain" {
+    run docopts --explain --from-script=../examples/naval_fate.sh : ship Guardian move 1 2
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "docopts:explain: matched usage line 2: naval_fate.sh ship <name> move <x> <y> [--speed=<kn>]" ]]
+
+    run docopts --explain --from-script=../examples/naval_fate.sh : ship Guardian move 1 --sped=3
+    [[ "${lines[0]}" == "docopts:explain: no usage line matched, closest is line 2: naval_fate.sh ship <name> move <x> <y> [--speed=<kn>]" ]]
+    [[ "${lines[1]}" == "docopts:explain: missing <y>" ]]
+    [[ "${lines[2]}" == "docopts:explain: unknown option --sped, did you mean --speed?" ]]
+    [[ "$output" == *"error: "* ]]
+
+    run docopts --json --explain --from-script=../examples/naval_fate.sh : mine 1 2
+    [[ $status -eq 1 ]]
+    [[ "${lines[0]}" == '{"matched":false,"line":4,"usage":"naval_fate.sh mine (set|remove) <x> <y> [--moored|--drifting]","issues":[{"reason":"missing","element":"set","message":"expected command set, got '"'1'"'"}]}' ]]
+}
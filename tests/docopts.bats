@@ -8,6 +8,8 @@
 source ../docopts.sh
 
 @test "docopt_get_help_string" {
+    # extracted by docopts --from-script
+    PATH=..:$PATH
     tmp=./tmp_docopt_get_help_string
     cat <<EOF > $tmp
 #!/usr/bin/env bash
@@ -99,3 +101,61 @@ EOF
     grep -q -E 'FILE,3' <<< "$output"
     grep -q -E 'ourargs' <<< "$output"
 }
+
+@test "docopt_get_json_array" {
+    PATH=..:$PATH
+    export DOCOPTS_JSON=$(docopts --json -h "usage: p FILE..." : one 'two  three' '*' $'four\n\n' '$(echo five)')
+    myarray=(previous values)
+    docopt_get_json_array FILE myarray
+    [[ ${#myarray[@]} -eq 5 ]]
+    [[ ${myarray[0]} == one ]]
+    [[ ${myarray[1]} == 'two  three' ]]
+    [[ ${myarray[2]} == '*' ]]
+    [[ ${myarray[3]} == $'four\n\n' ]]
+    [[ ${myarray[4]} == '$(echo five)' ]]
+}
+
+@test "docopt_get_version_string" {
+    PATH=..:$PATH
+    tmp=./tmp_docopt_get_version_string
+    cat <<EOF > $tmp
+#!/usr/bin/env bash
+# Usage: prog [--version]
+# ----
+# prog 1.0
+#	tab indented
+
+EOF
+    run docopt_get_version_string $tmp
+    [[ $status -eq 0 ]]
+    [[ "${lines[0]}" == "prog 1.0" ]]
+    [[ "${lines[1]}" == "tab indented" ]]
+
+    run docopt_get_version_string "prog 2.0"
+    [[ "$output" == "prog 2.0" ]]
+    rm -f $tmp
+}
+
+@test "docopt_complete" {
+    PATH=..:../examples:$PATH
+    COMP_WORDS=(naval_fate.sh ship "")
+    COMP_CWORD=2
+    docopt_complete naval_fate.sh
+    [[ "${COMPREPLY[*]}" == "new shoot" ]]
+
+    COMP_WORDS=(naval_fate.sh mine set 1 2 --m)
+    COMP_CWORD=5
+    docopt_complete naval_fate.sh
+    [[ "${COMPREPLY[*]}" == "--moored" ]]
+
+    # placeholders are left to the default completion
+    COMP_WORDS=(naval_fate.sh ship new boat "")
+    COMP_CWORD=4
+    docopt_complete naval_fate.sh
+    [[ ${#COMPREPLY[@]} -eq 0 ]]
+
+    COMP_WORDS=(not_a_command "")
+    COMP_CWORD=1
+    docopt_complete not_a_command
+    [[ ${#COMPREPLY[@]} -eq 0 ]]
+}